@@ -7,6 +7,8 @@
 package main
 
 import (
+	"log"
+	"net/http"
 	"os"
 )
 
@@ -17,6 +19,8 @@ func main() {
 		port = "3000"
 	}
 
-	server := NewServer()
-	server.Run(":" + port)
+	srv := &http.Server{Addr: ":" + port, Handler: NewServer()}
+	if err := run(srv); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,49 @@
+/*
+	Gumball API in Go (Version 3)
+	Graceful Shutdown
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Shutdown Deadline from Environment
+// The default stays under the 10s docker stop grace period.
+func shutdownConfig() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Second
+}
+
+// Serve until SIGTERM or SIGINT, then wait for in-flight
+// requests.  Orders are processed inside their request, so
+// nothing else needs draining.
+func run(srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Println("Listening on ", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-sigs:
+		fmt.Println("Shutting Down: ", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownConfig())
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
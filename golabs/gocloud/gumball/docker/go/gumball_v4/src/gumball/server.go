@@ -6,6 +6,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,8 +16,6 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
 	"github.com/satori/go.uuid"
-    "database/sql"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 /*
@@ -44,34 +44,18 @@ func NewServer() *negroni.Negroni {
 
 func init() {
 
-	db, err := sql.Open("mysql", mysql_connect)
+	pool, err := openDB(mysql_connect)
 	if err != nil {
 		log.Fatal(err)
+	}
+	db = pool
+	ctx, cancel := context.WithTimeout(context.Background(), mysql_timeout)
+	defer cancel()
+	if m, _, err := getGumball(ctx); err != nil {
+		fmt.Println("MySQL Ping Error: ", err)
 	} else {
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		rows, err := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			err := rows.Scan(&id, &count, &model, &serial)
-			if err != nil {
-				log.Fatal(err)
-			}
-			log.Println(id, count, model, serial)
-		}
-		err = rows.Err()
-		if err != nil {
-			log.Fatal(err)
-		}
+		log.Println("MySQL Ping: ", m)
 	}
-	defer db.Close()
 
 }
 
@@ -105,79 +89,58 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		result, version, err := getGumball(ctx)
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
-		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
+		w.Header().Set("ETag", versionTag(version))
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
 
 // API Update Gumball Inventory
+// Requires If-Match with the ETag from GET /gumball.
 func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-    	var m gumballMachine
-    	_ = json.NewDecoder(req.Body).Decode(&m)		
-    	fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
-	
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
-		stmt, err := db.Prepare("update gumball set count_gumballs = ? where id = ?")
-		if err != nil {
-			log.Fatal(err)
+		match := req.Header.Get("If-Match")
+		if match == "" {
+			formatter.JSON(w, http.StatusPreconditionRequired, struct{ Error string }{"If-Match Required"})
+			return
 		}
-		_, err = stmt.Exec( m.CountGumballs, 1)
-		if err != nil {
-			log.Fatal(err)
+		version, ok := matchVersion(match)
+		if !ok {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid If-Match"})
+			return
+		}
+		var m gumballMachine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
+		}
+		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		err := setGumballCount(ctx, m.CountGumballs, version)
+		if err == errVersionMismatch {
+			formatter.JSON(w, http.StatusPreconditionFailed, struct{ Error string }{"Gumball Machine Changed"})
+			return
 		}
-
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+		result, version, err := getGumball(ctx)
+		if err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
+		w.Header().Set("ETag", versionTag(version))
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
@@ -186,15 +149,17 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		uuid := uuid.NewV4()
-    	var ord = order {
-					Id: uuid.String(),            		
-					OrderStatus: "Order Placed",
+		var ord = order{
+			Id:          uuid.String(),
+			OrderStatus: order_placed,
 		}
-		if orders == nil {
-			orders = make(map[string]order)
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		if err := insertOrder(ctx, ord); err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		orders[uuid.String()] = ord
-		fmt.Println( "Orders: ", orders )
+		fmt.Println("Order: ", ord)
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -204,34 +169,45 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		params := mux.Vars(req)
 		var uuid string = params["id"]
-		fmt.Println( "Order ID: ", uuid )
-		if uuid == ""  {
-			fmt.Println( "Orders:", orders )
-			var orders_array [] order
-			for key, value := range orders {
-    			fmt.Println("Key:", key, "Value:", value)
-    			orders_array = append(orders_array, value)
+		fmt.Println("Order ID: ", uuid)
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		if uuid == "" {
+			orders_array, err := listOrders(ctx)
+			if err != nil {
+				dbErrorResponse(formatter, w, ctx, err)
+				return
 			}
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
-			var ord = orders[uuid]
-			fmt.Println( "Order: ", ord )
+			ord, err := getOrder(ctx, uuid)
+			if err == sql.ErrNoRows {
+				formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Order Not Found"})
+				return
+			}
+			if err != nil {
+				dbErrorResponse(formatter, w, ctx, err)
+				return
+			}
+			fmt.Println("Order: ", ord)
 			formatter.JSON(w, http.StatusOK, ord)
 		}
 	}
 }
 
-// API Process Orders 
+// API Process Orders
+// Orders placed while the machine is empty are failed.
 func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		for key, value := range orders {
-    			fmt.Println("Key:", key, "Value:", value)
-    			var ord = orders[key] 
-    			ord.OrderStatus = "Order Processed"
-    			orders[key] = ord
-			}
-		fmt.Println( "Orders: ", orders )
-		formatter.JSON(w, http.StatusOK, "Orders Processed!")
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		processed, failed, err := processOrders(ctx)
+		fmt.Println("Orders Processed: ", processed, " Failed: ", failed)
+		if err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
+		}
+		formatter.JSON(w, http.StatusOK, struct{ Processed, Failed int }{processed, failed})
 	}
 }
 
@@ -243,7 +219,7 @@ func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 
 		Database Schema: cmpe281
 
-	-- Create Database Table 
+	-- Create Database Tables
 
 		CREATE TABLE gumball (
 		  id bigint(20) NOT NULL AUTO_INCREMENT,
@@ -255,6 +231,14 @@ func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 		  UNIQUE KEY serial_number (serial_number)
 		) ;
 
+		CREATE TABLE orders (
+		  id varchar(36) NOT NULL,
+		  gumball_id bigint(20) NOT NULL,
+		  order_status varchar(255) NOT NULL,
+		  PRIMARY KEY (id),
+		  KEY order_status (order_status)
+		) ;
+
 	-- Load Data
 
 		insert into gumball ( id, version, count_gumballs, model_number, serial_number ) 
@@ -263,6 +247,7 @@ func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	-- Verify Data 
 
 		select * from gumball ;
+		select * from orders ;
 
 
 */
@@ -16,5 +16,3 @@ type order struct {
 	Id             	string 	
 	OrderStatus 	string	
 }
-
-var orders map[string] order
@@ -12,6 +12,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/satori/go.uuid"
 	"github.com/unrolled/render"
+	"gumball/journal"
+	"log"
 	"net/http"
 )
 
@@ -27,6 +29,53 @@ func NewServer() *negroni.Negroni {
 	return n
 }
 
+// Init Journal
+func init() {
+	restoreState()
+}
+
+// Restore State from Journal
+var jrnl *journal.Journal
+
+func restoreState() {
+	if orders == nil {
+		orders = make(map[string]order)
+	}
+	if dir, every := journal.Config(); dir != "" {
+		j, err := journal.Open(dir, every, &machine, &orders, applyJournalEntry)
+		if err != nil {
+			log.Fatal(err)
+		}
+		jrnl = j
+	}
+}
+
+func applyJournalEntry(e journal.Entry) {
+	switch e.Type {
+	case journal.Order, journal.Status:
+		orders[e.Id] = order{Id: e.Id, OrderStatus: e.OrderStatus}
+	case journal.Inventory:
+		machine.CountGumballs += e.Delta
+	}
+}
+
+// Journal Change
+// Callers hold mutex, so the snapshot sees no concurrent writes.
+func record(e journal.Entry) {
+	if jrnl == nil {
+		return
+	}
+	// Stop rather than acknowledge a change that is not on disk
+	if err := jrnl.Append(e); err != nil {
+		log.Fatalf("Journal Append: %v", err)
+	}
+	if jrnl.Due() {
+		if err := jrnl.Snapshot(machine, orders); err != nil {
+			fmt.Println("Journal Snapshot Error: ", err)
+		}
+	}
+}
+
 // API Routes
 func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/ping", pingHandler(formatter)).Methods("GET")
@@ -48,7 +97,10 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		formatter.JSON(w, http.StatusOK, machine)
+		mutex.Lock()
+		var m = machine
+		mutex.Unlock()
+		formatter.JSON(w, http.StatusOK, m)
 	}
 }
 
@@ -58,8 +110,13 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 		var m gumballMachine
 		_ = json.NewDecoder(req.Body).Decode(&m)
 		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+		mutex.Lock()
+		var delta = m.CountGumballs - machine.CountGumballs
 		machine.CountGumballs = m.CountGumballs
-		formatter.JSON(w, http.StatusOK, machine)
+		record(journal.Entry{Type: journal.Inventory, Delta: delta})
+		m = machine
+		mutex.Unlock()
+		formatter.JSON(w, http.StatusOK, m)
 	}
 }
 
@@ -71,11 +128,11 @@ func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 			Id:          uuid.String(),
 			OrderStatus: "Order Placed",
 		}
-		if orders == nil {
-			orders = make(map[string]order)
-		}
+		mutex.Lock()
 		orders[uuid.String()] = ord
-		fmt.Println("Orders: ", orders)
+		record(journal.Entry{Type: journal.Order, Id: ord.Id, OrderStatus: ord.OrderStatus})
+		mutex.Unlock()
+		fmt.Println("Order: ", ord)
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -87,15 +144,18 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 		var uuid string = params["id"]
 		fmt.Println("Order ID: ", uuid)
 		if uuid == "" {
-			fmt.Println("Orders:", orders)
 			var orders_array []order
+			mutex.Lock()
 			for key, value := range orders {
 				fmt.Println("Key:", key, "Value:", value)
 				orders_array = append(orders_array, value)
 			}
+			mutex.Unlock()
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
+			mutex.Lock()
 			var ord = orders[uuid]
+			mutex.Unlock()
 			fmt.Println("Order: ", ord)
 			formatter.JSON(w, http.StatusOK, ord)
 		}
@@ -105,13 +165,15 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 // API Process Orders
 func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
 		for key, value := range orders {
 			fmt.Println("Key:", key, "Value:", value)
 			var ord = orders[key]
 			ord.OrderStatus = "Order Processed"
 			orders[key] = ord
+			record(journal.Entry{Type: journal.Status, Id: ord.Id, OrderStatus: ord.OrderStatus})
 		}
-		fmt.Println("Orders: ", orders)
+		mutex.Unlock()
 		formatter.JSON(w, http.StatusOK, "Orders Processed!")
 	}
 }
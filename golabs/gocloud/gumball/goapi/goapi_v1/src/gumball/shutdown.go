@@ -0,0 +1,73 @@
+/*
+	Gumball API in Go (Version 1)
+	Graceful Shutdown
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+/*
+	On SIGTERM or SIGINT the server stops accepting requests and
+	waits for in-flight requests, then snapshots and closes the
+	journal.
+
+	Environment:
+		SHUTDOWN_TIMEOUT	drain deadline (default 8s, under
+					the 10s docker stop grace period)
+*/
+
+// Shutdown Deadline from Environment
+func shutdownConfig() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Second
+}
+
+// Serve until SIGTERM or SIGINT, then drain
+func run(srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Println("Listening on ", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-sigs:
+		fmt.Println("Shutting Down: ", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownConfig())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("Server Shutdown Error: ", err)
+	}
+	return closeJournal()
+}
+
+func closeJournal() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if jrnl == nil {
+		return nil
+	}
+	err := jrnl.Snapshot(machine, orders)
+	if cerr := jrnl.Close(); err == nil {
+		err = cerr
+	}
+	fmt.Println("Journal Closed")
+	return err
+}
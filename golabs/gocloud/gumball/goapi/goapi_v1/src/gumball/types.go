@@ -5,6 +5,8 @@
 
 package main
 
+import "sync"
+
 type gumballMachine struct {
 	Id            int
 	CountGumballs int
@@ -24,4 +26,5 @@ type order struct {
 	OrderStatus string
 }
 
+var mutex = &sync.Mutex{}
 var orders map[string]order
@@ -0,0 +1,51 @@
+/*
+	Gumball API in Go (Version 2)
+	Order Lifecycle
+*/
+
+package main
+
+import "errors"
+
+/*
+	Order State Machine
+
+		Placed ---> Processing ---> Dispensed
+		  |              |
+		  v              v
+		Cancelled      Failed (Out of Stock)
+
+	Dispensed, Cancelled and Failed are final.  The statuses match
+	the go-gumball store so both APIs report the same strings.
+*/
+
+type orderStatus string
+
+const (
+	orderPlaced     orderStatus = "Order Placed"
+	orderProcessing orderStatus = "Order Processing"
+	orderDispensed  orderStatus = "Order Dispensed"
+	orderCancelled  orderStatus = "Order Cancelled"
+	orderFailed     orderStatus = "Order Failed"
+)
+
+// Failure Reasons
+const reasonOutOfStock = "Out of Stock"
+
+var order_transitions = map[orderStatus][]orderStatus{
+	orderPlaced:     {orderProcessing, orderCancelled},
+	orderProcessing: {orderDispensed, orderFailed},
+}
+
+// Order Errors
+var errOrderNotFound = errors.New("order not found")
+var errInvalidTransition = errors.New("invalid order transition")
+
+func (s orderStatus) canTransition(next orderStatus) bool {
+	for _, to := range order_transitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/satori/go.uuid"
 	"github.com/unrolled/render"
+	"gumball/journal"
+	"log"
 	"net/http"
 	"time"
 )
@@ -30,11 +32,63 @@ func NewServer() *negroni.Negroni {
 
 // Init Background Processes
 func init() {
+	restoreState()
 	for i := 1; i < 2; i++ {
+		workers_done.Add(1)
 		go gumballOrdersWorker()
 	}
 }
 
+// Restore State from Journal
+var jrnl *journal.Journal
+
+func restoreState() {
+	if orders == nil {
+		orders = make(map[string]order)
+	}
+	if dir, every := journal.Config(); dir != "" {
+		j, err := journal.Open(dir, every, &machine, &orders, applyJournalEntry)
+		if err != nil {
+			log.Fatal(err)
+		}
+		jrnl = j
+	}
+	// No worker holds an order across a restart
+	for key, value := range orders {
+		if value.OrderStatus == orderProcessing {
+			value.OrderStatus = orderPlaced
+			orders[key] = value
+		}
+	}
+}
+
+func applyJournalEntry(e journal.Entry) {
+	switch e.Type {
+	case journal.Order, journal.Status:
+		orders[e.Id] = order{Id: e.Id, OrderStatus: orderStatus(e.OrderStatus), Reason: e.Reason}
+		machine.CountGumballs += e.Delta
+	case journal.Inventory:
+		machine.CountGumballs += e.Delta
+	}
+}
+
+// Journal Change
+// Callers hold mutex, so the snapshot sees no concurrent writes.
+func record(e journal.Entry) {
+	if jrnl == nil {
+		return
+	}
+	// Stop rather than acknowledge a change that is not on disk
+	if err := jrnl.Append(e); err != nil {
+		log.Fatalf("Journal Append: %v", err)
+	}
+	if jrnl.Due() {
+		if err := jrnl.Snapshot(machine, orders); err != nil {
+			fmt.Println("Journal Snapshot Error: ", err)
+		}
+	}
+}
+
 // API Routes
 func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/ping", pingHandler(formatter)).Methods("GET")
@@ -43,6 +97,7 @@ func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/order", gumballNewOrderHandler(formatter)).Methods("POST")
 	mx.HandleFunc("/order/{id}", gumballOrderStatusHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/order", gumballOrderStatusHandler(formatter)).Methods("GET")
+	mx.HandleFunc("/order/{id}", gumballCancelOrderHandler(formatter)).Methods("DELETE")
 	mx.HandleFunc("/orders", gumballProcessOrdersHandler(formatter)).Methods("POST")
 }
 
@@ -56,7 +111,10 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		formatter.JSON(w, http.StatusOK, machine)
+		mutex.Lock()
+		var m = machine
+		mutex.Unlock()
+		formatter.JSON(w, http.StatusOK, m)
 	}
 }
 
@@ -66,10 +124,17 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 		var m gumballMachine
 		_ = json.NewDecoder(req.Body).Decode(&m)
 		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+		if m.CountGumballs < 0 {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
+		}
 		mutex.Lock()
+		var delta = m.CountGumballs - machine.CountGumballs
 		machine.CountGumballs = m.CountGumballs
+		record(journal.Entry{Type: journal.Inventory, Delta: delta})
+		m = machine
 		mutex.Unlock()
-		formatter.JSON(w, http.StatusOK, machine)
+		formatter.JSON(w, http.StatusOK, m)
 	}
 }
 
@@ -79,13 +144,13 @@ func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 		uuid := uuid.NewV4()
 		var ord = order{
 			Id:          uuid.String(),
-			OrderStatus: "Order Placed",
-		}
-		if orders == nil {
-			orders = make(map[string]order)
+			OrderStatus: orderPlaced,
 		}
+		mutex.Lock()
 		orders[uuid.String()] = ord
-		fmt.Println("Orders: ", orders)
+		record(journal.Entry{Type: journal.Order, Id: ord.Id, OrderStatus: string(ord.OrderStatus)})
+		mutex.Unlock()
+		fmt.Println("Order: ", ord)
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -97,15 +162,18 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 		var uuid string = params["id"]
 		fmt.Println("Order ID: ", uuid)
 		if uuid == "" {
-			fmt.Println("Orders:", orders)
 			var orders_array []order
+			mutex.Lock()
 			for key, value := range orders {
 				fmt.Println("Key:", key, "Value:", value)
 				orders_array = append(orders_array, value)
 			}
+			mutex.Unlock()
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
+			mutex.Lock()
 			var ord = orders[uuid]
+			mutex.Unlock()
 			fmt.Println("Order: ", ord)
 			formatter.JSON(w, http.StatusOK, ord)
 		}
@@ -115,27 +183,98 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 // API Process Orders
 func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		for key, _ := range orders {
-			order_queue <- key
+		var keys []string
+		mutex.Lock()
+		for key := range orders {
+			keys = append(keys, key)
+		}
+		mutex.Unlock()
+	queue:
+		for _, key := range keys {
+			select {
+			case order_queue <- key:
+			case <-shutdown:
+				break queue
+			}
 		}
 		formatter.JSON(w, http.StatusOK, "Processing Orders...")
 	}
 }
 
+// API Cancel Order
+func gumballCancelOrderHandler(formatter *render.Render) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		ord, err := transitionOrder(params["id"], orderCancelled)
+		switch err {
+		case nil:
+		case errOrderNotFound:
+			formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Not Found"})
+			return
+		default:
+			formatter.JSON(w, http.StatusConflict, struct{ Error string }{"Invalid Order Transition"})
+			return
+		}
+		fmt.Println("Cancelled Order: ", ord)
+		formatter.JSON(w, http.StatusOK, ord)
+	}
+}
+
+// Move an Order to its Next Status
+// Moving to Dispensed takes a gumball; with none left the order
+// is failed instead.
+func transitionOrder(key string, to orderStatus) (order, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	var ord, ok = orders[key]
+	if !ok {
+		return ord, errOrderNotFound
+	}
+	if !ord.OrderStatus.canTransition(to) {
+		return ord, errInvalidTransition
+	}
+	// One entry for the status and the gumball it takes
+	var delta int
+	ord.OrderStatus = to
+	if to == orderDispensed {
+		if machine.CountGumballs < 1 {
+			ord.OrderStatus = orderFailed
+			ord.Reason = reasonOutOfStock
+		} else {
+			delta = -1
+			machine.CountGumballs += delta
+		}
+	}
+	orders[key] = ord
+	record(journal.Entry{Type: journal.Status, Id: ord.Id, OrderStatus: string(ord.OrderStatus), Reason: ord.Reason, Delta: delta})
+	return ord, nil
+}
 
 // Server Orders from Order Queue
+// Stops taking orders once shutdown is closed; an order still
+// being processed when abort is closed stays Processing and is
+// placed again when the journal is restored.
 func gumballOrdersWorker() {
+	defer workers_done.Done()
 	for {
-		order_key := <- order_queue
-		mutex.Lock()
-		var order = orders[order_key]
-		time.Sleep(5000 * time.Millisecond)
-		order.OrderStatus = "Order Processed"
-		orders[order_key] = order
-		cnt := machine.CountGumballs
-		cnt = cnt - 1		
-		machine.CountGumballs = cnt
-		mutex.Unlock()
+		var order_key string
+		select {
+		case order_key = <-order_queue:
+		case <-shutdown:
+			return
+		}
+		// Cancelled, unknown or already processed
+		var order, err = transitionOrder(order_key, orderProcessing)
+		if err != nil {
+			continue
+		}
+		select {
+		case <-time.After(5000 * time.Millisecond):
+		case <-abort:
+			fmt.Println("Requeued Order: ", order)
+			return
+		}
+		order, _ = transitionOrder(order_key, orderDispensed)
 		fmt.Println("Processed Order: ", order)
 	}
 }
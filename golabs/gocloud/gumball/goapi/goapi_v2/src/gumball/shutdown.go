@@ -0,0 +1,95 @@
+/*
+	Gumball API in Go (Version 2)
+	Graceful Shutdown
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+	On SIGTERM or SIGINT the server stops accepting requests and
+	waits for in-flight requests and orders.  Workers take no new
+	orders from the queue; an order still being processed when the
+	deadline passes stays Processing in the journal, is placed
+	again on restore, and POST /orders picks it up after a
+	restart.  The journal is snapshotted and closed last.
+
+	Environment:
+		SHUTDOWN_TIMEOUT	drain deadline (default 8s, under
+					the 10s docker stop grace period)
+*/
+
+// Shutdown Signals to Workers
+var shutdown = make(chan struct{})
+var abort = make(chan struct{})
+var workers_done sync.WaitGroup
+
+// Shutdown Deadline from Environment
+func shutdownConfig() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Second
+}
+
+// Serve until SIGTERM or SIGINT, then drain
+func run(srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Println("Listening on ", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-sigs:
+		fmt.Println("Shutting Down: ", sig)
+	}
+
+	close(shutdown)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownConfig())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("Server Shutdown Error: ", err)
+	}
+	return drain(ctx)
+}
+
+// Wait for the order workers and flush the journal
+func drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workers_done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		close(abort)
+		<-done
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if jrnl == nil {
+		return nil
+	}
+	err := jrnl.Snapshot(machine, orders)
+	if cerr := jrnl.Close(); err == nil {
+		err = cerr
+	}
+	fmt.Println("Journal Closed")
+	return err
+}
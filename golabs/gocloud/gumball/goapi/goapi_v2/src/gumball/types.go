@@ -23,7 +23,8 @@ var machine gumballMachine = gumballMachine{
 
 type order struct {
 	Id          string
-	OrderStatus string
+	OrderStatus orderStatus
+	Reason      string `json:",omitempty"`
 }
 
 var mutex = &sync.Mutex{}
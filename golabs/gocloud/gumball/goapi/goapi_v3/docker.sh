@@ -60,7 +60,7 @@ docker_build() {
 	if [ "$AUTH" != "TRUE" ] ; 
     then echo "Login Required!" ; 
     else 
- 	  	docker build -t $ACCOUNT/$CONTAINER:$VERSION .
+ 	  	docker build -t $ACCOUNT/$CONTAINER:$VERSION -f Dockerfile ..
     fi ; 
 }
 
@@ -69,7 +69,7 @@ docker_release() {
     then echo "Login Required!" ; 
     else 
   		echo "Building Versions: latest and $VERSION"
- 	  	docker build -t $ACCOUNT/$CONTAINER:latest -t $ACCOUNT/$CONTAINER:$VERSION .
+ 	  	docker build -t $ACCOUNT/$CONTAINER:latest -t $ACCOUNT/$CONTAINER:$VERSION -f Dockerfile ..
  	  	echo "Pushing Builds to Docker Hub"
  	  	docker push $ACCOUNT/$CONTAINER:latest ; 
  	  	docker push $ACCOUNT/$CONTAINER:$VERSION ; 
@@ -0,0 +1,105 @@
+/*
+	Gumball API in Go (Version 3)
+	Order Queue Admission
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/unrolled/render"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+	Orders are queued without blocking the HTTP handler.  POST /order
+	queues the new order and POST /orders queues placed orders that
+	are not already queued, such as those restored from the journal.
+	When the queue is full, POST /orders answers 429 and POST /order
+	answers 503 without creating the order, both with a Retry-After
+	estimated from the queue depth.
+
+	Environment:
+		ORDER_QUEUE	queue capacity (default 10)
+		ORDER_WORKERS	number of order workers (default 1)
+*/
+
+// Queue Config from Environment
+func queueConfig() (int, int) {
+	size, workers := 10, 1
+	if n, err := strconv.Atoi(os.Getenv("ORDER_QUEUE")); err == nil && n > 0 {
+		size = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("ORDER_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	return size, workers
+}
+
+// Enqueue a placed order unless it is queued or the queue is full
+func enqueueOrder(key string) (queued bool, full bool) {
+	op := &orderEnqueueOp{key: key, resp: make(chan bool)}
+	order_enqueues <- op
+	queued = <-op.resp
+	return queued, op.full
+}
+
+// Seconds until the queued orders should have drained
+func retryAfter() int {
+	wait := time.Duration(len(order_queue)) * order_process_time / time.Duration(order_workers)
+	secs := int((wait + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+func busyResponse(formatter *render.Render, w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter()))
+	formatter.JSON(w, status, v)
+}
+
+// API Order Queue Depth
+func gumballQueueHandler(formatter *render.Render) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		formatter.JSON(w, http.StatusOK, struct {
+			Depth    int
+			Capacity int
+			Workers  int
+		}{len(order_queue), cap(order_queue), order_workers})
+	}
+}
+
+// API Process Orders
+// Placed orders are queued until the queue is full; the rest
+// are left placed for the client to retry.  Orders already in
+// the queue are skipped.
+func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var queued, rejected int
+		for _, ord := range getOrders() {
+			if ord.OrderStatus != orderPlaced {
+				continue
+			}
+			switch ok, full := enqueueOrder(ord.Id); {
+			case ok:
+				queued++
+			case full:
+				rejected++
+			}
+		}
+		result := struct {
+			Queued   int
+			Rejected int
+		}{queued, rejected}
+		fmt.Println("Processing Orders: ", result)
+		if rejected > 0 {
+			busyResponse(formatter, w, http.StatusTooManyRequests, result)
+			return
+		}
+		formatter.JSON(w, http.StatusOK, result)
+	}
+}
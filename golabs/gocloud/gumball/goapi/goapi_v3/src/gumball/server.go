@@ -13,6 +13,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/satori/go.uuid"
 	"github.com/unrolled/render"
+	"gumball/journal"
+	"log"
 	"net/http"
 	"time"
 )
@@ -31,10 +33,14 @@ func NewServer() *negroni.Negroni {
 
 // Init Background Processes
 func init() {
-	for i := 1; i < 2; i++ {
-		go gumballOrdersWorker()
+	var size int
+	size, order_workers = queueConfig()
+	order_queue = make(chan string, size)
+	workers_done.Add(order_workers)
+	for i := 0; i < order_workers; i++ {
+		go gumballOrdersWorker(order_queue)
 	}
-	go gumballMachineUpdateWorker()
+	go gumballStateWorker()
 }
 
 // API Routes
@@ -45,7 +51,9 @@ func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/order", gumballNewOrderHandler(formatter)).Methods("POST")
 	mx.HandleFunc("/order/{id}", gumballOrderStatusHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/order", gumballOrderStatusHandler(formatter)).Methods("GET")
+	mx.HandleFunc("/order/{id}", gumballCancelOrderHandler(formatter)).Methods("DELETE")
 	mx.HandleFunc("/orders", gumballProcessOrdersHandler(formatter)).Methods("POST")
+	mx.HandleFunc("/queue", gumballQueueHandler(formatter)).Methods("GET")
 }
 
 // API Ping Handler
@@ -58,7 +66,7 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		formatter.JSON(w, http.StatusOK, machine)
+		formatter.JSON(w, http.StatusOK, getMachine())
 	}
 }
 
@@ -68,7 +76,11 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 		var m gumballMachine
 		_ = json.NewDecoder(req.Body).Decode(&m)
 		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
-		order_update <- m.CountGumballs
+		machine, ok := setMachineCount(m.CountGumballs)
+		if !ok {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
+		}
 		formatter.JSON(w, http.StatusOK, machine)
 	}
 }
@@ -79,13 +91,13 @@ func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 		uuid := uuid.NewV4()
 		var ord = order{
 			Id:          uuid.String(),
-			OrderStatus: "Order Placed",
+			OrderStatus: orderPlaced,
 		}
-		if orders == nil {
-			orders = make(map[string]order)
+		if !admitOrder(ord) {
+			busyResponse(formatter, w, http.StatusServiceUnavailable, struct{ Error string }{"Order Queue Full"})
+			return
 		}
-		orders[uuid.String()] = ord
-		fmt.Println("Orders: ", orders)
+		fmt.Println("Order: ", ord)
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -97,55 +109,285 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 		var uuid string = params["id"]
 		fmt.Println("Order ID: ", uuid)
 		if uuid == "" {
-			fmt.Println("Orders:", orders)
-			var orders_array []order
-			for key, value := range orders {
-				fmt.Println("Key:", key, "Value:", value)
-				orders_array = append(orders_array, value)
-			}
+			var orders_array = getOrders()
+			fmt.Println("Orders:", orders_array)
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
-			var ord = orders[uuid]
+			var ord, _ = getOrder(uuid)
 			fmt.Println("Order: ", ord)
 			formatter.JSON(w, http.StatusOK, ord)
 		}
 	}
 }
 
-// API Process Orders
-func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
+// API Cancel Order
+func gumballCancelOrderHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		for key, _ := range orders {
-			order_queue <- key
+		params := mux.Vars(req)
+		ord, err := transitionOrder(params["id"], orderCancelled)
+		switch err {
+		case nil:
+		case errOrderNotFound:
+			formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Not Found"})
+			return
+		default:
+			formatter.JSON(w, http.StatusConflict, struct{ Error string }{"Invalid Order Transition"})
+			return
 		}
-		formatter.JSON(w, http.StatusOK, "Processing Orders...")
+		fmt.Println("Cancelled Order: ", ord)
+		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
 
-
 // Server Orders from Order Queue
-func gumballOrdersWorker() {
+// Stops taking orders once shutdown is closed; an order still
+// being processed when abort is closed stays Processing and is
+// placed again when the journal is restored.
+func gumballOrdersWorker(queue <-chan string) {
+	defer workers_done.Done()
 	for {
-		order_key := <- order_queue
-		var order = orders[order_key]
-		time.Sleep(5000 * time.Millisecond)
-		order.OrderStatus = "Order Processed"
-		orders[order_key] = order
-		order_update <- -1
+		var order_key string
+		select {
+		case <-shutdown:
+			return
+		default:
+		}
+		select {
+		case order_key = <-queue:
+		case <-shutdown:
+			return
+		}
+		// Cancelled, unknown or claimed by another worker
+		var order, err = transitionOrder(order_key, orderProcessing)
+		if err != nil {
+			continue
+		}
+		select {
+		case <-time.After(order_process_time):
+		case <-abort:
+			fmt.Println("Requeued Order: ", order)
+			return
+		}
+		order, _ = transitionOrder(order_key, orderDispensed)
 		fmt.Println("Processed Order: ", order)
 	}
 }
 
+// Own Machine and Orders State
+func gumballStateWorker() {
+	var machine = gumballMachine{
+		Id:            1,
+		CountGumballs: 900,
+		ModelNumber:   "M102988",
+		SerialNumber:  "1234998871109",
+	}
+	var orders = make(map[string]order)
+	var queued = make(map[string]bool)
+
+	// Restore from Journal
+	var jrnl *journal.Journal
+	if dir, every := journal.Config(); dir != "" {
+		j, err := openJournal(dir, every, &machine, orders)
+		if err != nil {
+			log.Fatal(err)
+		}
+		jrnl = j
+	}
+	record := func(e journal.Entry) {
+		if jrnl == nil {
+			return
+		}
+		// Stop rather than acknowledge a change that is not on disk
+		if err := jrnl.Append(e); err != nil {
+			log.Fatalf("Journal Append: %v", err)
+		}
+		if jrnl.Due() {
+			if err := jrnl.Snapshot(machine, orders); err != nil {
+				fmt.Println("Journal Snapshot Error: ", err)
+			}
+		}
+	}
+
+	// Never blocks; an order is queued at most once while placed
+	enqueue := func(key string) bool {
+		select {
+		case order_queue <- key:
+			queued[key] = true
+			return true
+		default:
+			return false
+		}
+	}
 
-// Update Machine Inventory
-func gumballMachineUpdateWorker() {
 	for {
-		chg := <- order_update
-		fmt.Println("Updating Machine: ", chg)
-		cnt := machine.CountGumballs
-		cnt = cnt + chg		
-		machine.CountGumballs = cnt
-		fmt.Println("Updated Machine: ", cnt)
+		select {
+		case read := <-machine_reads:
+			read.resp <- machine
+		case write := <-machine_writes:
+			var delta = write.count
+			if !write.delta {
+				delta = write.count - machine.CountGumballs
+			}
+			if machine.CountGumballs+delta < 0 {
+				write.resp <- machine
+				break
+			}
+			write.ok = true
+			machine.CountGumballs += delta
+			record(journal.Entry{Type: journal.Inventory, Delta: delta})
+			fmt.Println("Updated Machine: ", machine.CountGumballs)
+			write.resp <- machine
+		case read := <-order_reads:
+			var result []order
+			if read.key == "" {
+				for _, value := range orders {
+					result = append(result, value)
+				}
+			} else if ord, ok := orders[read.key]; ok {
+				result = append(result, ord)
+			}
+			read.resp <- result
+		case write := <-order_writes:
+			if write.enqueue && !enqueue(write.ord.Id) {
+				write.resp <- false
+				break
+			}
+			var entry_type = journal.Status
+			if _, ok := orders[write.ord.Id]; !ok {
+				entry_type = journal.Order
+			}
+			orders[write.ord.Id] = write.ord
+			record(journal.Entry{Type: entry_type, Id: write.ord.Id, OrderStatus: string(write.ord.OrderStatus), Reason: write.ord.Reason})
+			write.resp <- true
+		case op := <-order_enqueues:
+			var ord, ok = orders[op.key]
+			if !ok || ord.OrderStatus != orderPlaced || queued[op.key] {
+				op.resp <- false
+				break
+			}
+			op.full = !enqueue(op.key)
+			op.resp <- !op.full
+		case update := <-order_updates:
+			var ord, ok = orders[update.key]
+			if !ok {
+				update.err = errOrderNotFound
+				update.resp <- ord
+				break
+			}
+			if !ord.OrderStatus.canTransition(update.to) {
+				update.err = errInvalidTransition
+				update.resp <- ord
+				break
+			}
+			// One entry for the status and the gumball it takes
+			var delta int
+			ord.OrderStatus = update.to
+			if update.to == orderDispensed {
+				if machine.CountGumballs < 1 {
+					ord.OrderStatus = orderFailed
+					ord.Reason = reasonOutOfStock
+				} else {
+					delta = -1
+					machine.CountGumballs += delta
+				}
+			}
+			orders[ord.Id] = ord
+			delete(queued, ord.Id)
+			record(journal.Entry{Type: journal.Status, Id: ord.Id, OrderStatus: string(ord.OrderStatus), Reason: ord.Reason, Delta: delta})
+			update.resp <- ord
+		case resp := <-state_stop:
+			var err error
+			if jrnl != nil {
+				err = jrnl.Snapshot(machine, orders)
+				if cerr := jrnl.Close(); err == nil {
+					err = cerr
+				}
+			}
+			fmt.Println("Journal Closed")
+			resp <- err
+			return
+		}
+	}
+}
+
+// Open Journal and Restore State
+// No worker holds an order across a restart, so orders that were
+// Processing are placed again.
+func openJournal(dir string, every int, m *gumballMachine, orders map[string]order) (*journal.Journal, error) {
+	j, err := journal.Open(dir, every, m, &orders, func(e journal.Entry) {
+		switch e.Type {
+		case journal.Order, journal.Status:
+			orders[e.Id] = order{Id: e.Id, OrderStatus: orderStatus(e.OrderStatus), Reason: e.Reason}
+			m.CountGumballs += e.Delta
+		case journal.Inventory:
+			m.CountGumballs += e.Delta
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range orders {
+		if value.OrderStatus == orderProcessing {
+			value.OrderStatus = orderPlaced
+			orders[key] = value
+		}
+	}
+	return j, nil
+}
+
+// State Helper Functions
+func getMachine() gumballMachine {
+	read := &machineReadOp{resp: make(chan gumballMachine)}
+	machine_reads <- read
+	return <-read.resp
+}
+
+func setMachineCount(count int) (gumballMachine, bool) {
+	write := &machineWriteOp{count: count, resp: make(chan gumballMachine)}
+	machine_writes <- write
+	m := <-write.resp
+	return m, write.ok
+}
+
+func adjustMachineCount(delta int) (gumballMachine, bool) {
+	write := &machineWriteOp{count: delta, delta: true, resp: make(chan gumballMachine)}
+	machine_writes <- write
+	m := <-write.resp
+	return m, write.ok
+}
+
+func getOrders() []order {
+	read := &orderReadOp{resp: make(chan []order)}
+	order_reads <- read
+	return <-read.resp
+}
+
+func getOrder(key string) (order, bool) {
+	read := &orderReadOp{key: key, resp: make(chan []order)}
+	order_reads <- read
+	result := <-read.resp
+	if len(result) == 0 {
+		return order{}, false
 	}
+	return result[0], true
+}
+
+func putOrder(ord order) {
+	write := &orderWriteOp{ord: ord, resp: make(chan bool)}
+	order_writes <- write
+	<-write.resp
 }
 
+// Store a new order and queue it, unless the queue is full
+func admitOrder(ord order) bool {
+	write := &orderWriteOp{ord: ord, enqueue: true, resp: make(chan bool)}
+	order_writes <- write
+	return <-write.resp
+}
+
+func transitionOrder(key string, to orderStatus) (order, error) {
+	update := &orderTransitionOp{key: key, to: to, resp: make(chan order)}
+	order_updates <- update
+	ord := <-update.resp
+	return ord, update.err
+}
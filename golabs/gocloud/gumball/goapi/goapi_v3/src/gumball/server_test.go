@@ -0,0 +1,306 @@
+/*
+	Gumball API in Go (Version 3)
+	Process Order with Go Channels
+	Removed Use of Mutex
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/satori/go.uuid"
+	"gumball/journal"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	order_process_time = time.Millisecond
+}
+
+func TestConcurrentRoutes(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	before := len(getOrders())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				ord := placeOrder(t, ts.URL)
+				doRequest(t, "GET", ts.URL+"/order/"+ord.Id, "", nil)
+				doRequest(t, "GET", ts.URL+"/order", "", nil)
+				doRequest(t, "GET", ts.URL+"/gumball", "", nil)
+				if j%5 == 0 {
+					doRequest(t, "PUT", ts.URL+"/gumball", `{"CountGumballs": 500}`, nil)
+					tryRequest(t, "POST", ts.URL+"/orders", "", nil)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var all []order
+	doRequest(t, "GET", ts.URL+"/order", "", &all)
+	if len(all) != before+80 {
+		t.Errorf("got %d orders, want %d", len(all), before+80)
+	}
+}
+
+func TestOrderProcessed(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+
+	ord := placeOrder(t, ts.URL)
+	if ord.OrderStatus != orderPlaced {
+		t.Fatalf("new order status = %q", ord.OrderStatus)
+	}
+	before := getMachine().CountGumballs
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		// Orders left by other tests may hold the queue; keep retrying
+		tryRequest(t, "POST", ts.URL+"/orders", "", nil)
+		var got order
+		doRequest(t, "GET", ts.URL+"/order/"+ord.Id, "", &got)
+		if got.OrderStatus == orderDispensed {
+			if after := getMachine().CountGumballs; after >= before {
+				t.Errorf("inventory %d not decremented from %d", after, before)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("order was not processed")
+}
+
+func TestOrderOutOfStock(t *testing.T) {
+	ts := httptest.NewServer(NewServer())
+	defer ts.Close()
+	defer setMachineCount(500)
+
+	doRequest(t, "PUT", ts.URL+"/gumball", `{"CountGumballs": 0}`, nil)
+	ord := placeOrder(t, ts.URL)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		// Orders left by other tests may hold the queue; keep retrying
+		tryRequest(t, "POST", ts.URL+"/orders", "", nil)
+		var got order
+		doRequest(t, "GET", ts.URL+"/order/"+ord.Id, "", &got)
+		if got.OrderStatus == orderFailed && got.Reason == reasonOutOfStock {
+			if count := getMachine().CountGumballs; count != 0 {
+				t.Errorf("inventory = %d, want 0", count)
+			}
+			return
+		}
+		if got.OrderStatus != orderPlaced && got.OrderStatus != orderProcessing {
+			t.Fatalf("order status = %q, want Order Failed", got.OrderStatus)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("order was not failed")
+}
+
+func TestCancelOrder(t *testing.T) {
+	server := NewServer()
+	putOrder(order{Id: "cancel-1", OrderStatus: orderPlaced})
+
+	rec := serve(server, "DELETE", "/order/cancel-1")
+	var got order
+	json.NewDecoder(rec.Body).Decode(&got)
+	if rec.Code != http.StatusOK || got.OrderStatus != orderCancelled {
+		t.Errorf("DELETE /order = %d, %q", rec.Code, got.OrderStatus)
+	}
+	if rec = serve(server, "DELETE", "/order/cancel-1"); rec.Code != http.StatusConflict {
+		t.Errorf("DELETE cancelled order = %d, want 409", rec.Code)
+	}
+	if rec = serve(server, "DELETE", "/order/cancel-missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE unknown order = %d, want 404", rec.Code)
+	}
+
+	// A cancelled order is never processed
+	if _, err := transitionOrder("cancel-1", orderProcessing); err != errInvalidTransition {
+		t.Errorf("claim cancelled order: %v", err)
+	}
+}
+
+func TestOrderClaimedOnce(t *testing.T) {
+	putOrder(order{Id: "claim-1", OrderStatus: orderPlaced})
+	if _, err := transitionOrder("claim-1", orderProcessing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transitionOrder("claim-1", orderProcessing); err != errInvalidTransition {
+		t.Errorf("second claim: %v", err)
+	}
+	if _, err := transitionOrder("claim-1", orderCancelled); err != errInvalidTransition {
+		t.Errorf("cancel processing order: %v", err)
+	}
+}
+
+func TestNegativeInventory(t *testing.T) {
+	if _, ok := setMachineCount(-1); ok {
+		t.Error("setMachineCount(-1) accepted")
+	}
+	if _, ok := adjustMachineCount(-getMachine().CountGumballs - 1); ok {
+		t.Error("adjustMachineCount below zero accepted")
+	}
+	if count := getMachine().CountGumballs; count < 0 {
+		t.Errorf("inventory = %d", count)
+	}
+}
+
+func TestBackPressure(t *testing.T) {
+	// Swap in a small queue that no worker reads
+	saved := order_queue
+	order_queue = make(chan string, 2)
+	defer func() { order_queue = saved }()
+
+	for i := 0; i < 3; i++ {
+		putOrder(order{Id: testOrderId("bp"), OrderStatus: orderPlaced})
+	}
+	server := NewServer()
+
+	rec := serve(server, "POST", "/orders")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("POST /orders on full queue = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var result struct{ Queued, Rejected int }
+	json.NewDecoder(rec.Body).Decode(&result)
+	if result.Queued != 2 || result.Rejected < 1 {
+		t.Errorf("POST /orders = %+v, want 2 queued and some rejected", result)
+	}
+
+	rec = serve(server, "POST", "/order")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("POST /order on full queue = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	rec = serve(server, "GET", "/queue")
+	var depth struct{ Depth, Capacity, Workers int }
+	json.NewDecoder(rec.Body).Decode(&depth)
+	if depth.Depth != 2 || depth.Capacity != 2 || depth.Workers != order_workers {
+		t.Errorf("GET /queue = %+v", depth)
+	}
+}
+
+func TestOrderQueuedOnce(t *testing.T) {
+	// Swap in a queue that no worker reads
+	saved := order_queue
+	order_queue = make(chan string, 4)
+	defer func() { order_queue = saved }()
+
+	id := testOrderId("once")
+	putOrder(order{Id: id, OrderStatus: orderPlaced})
+	if ok, full := enqueueOrder(id); !ok || full {
+		t.Errorf("first enqueue = %v, %v", ok, full)
+	}
+	if ok, full := enqueueOrder(id); ok || full {
+		t.Errorf("second enqueue = %v, %v, want skipped", ok, full)
+	}
+
+	// POST /order queues the order it creates
+	rec := serve(NewServer(), "POST", "/order")
+	if rec.Code != http.StatusOK || len(order_queue) != 2 {
+		t.Errorf("POST /order = %d, queue depth %d, want 2", rec.Code, len(order_queue))
+	}
+}
+
+// Orders once queued stay queued, so each run of a test that
+// queues orders places new ones.
+func testOrderId(prefix string) string {
+	return prefix + "-" + uuid.NewV4().String()
+}
+
+func TestJournalPlacesUnfinishedOrders(t *testing.T) {
+	dir := t.TempDir()
+	machine := gumballMachine{CountGumballs: 10}
+	orders := make(map[string]order)
+
+	j, err := openJournal(dir, 1000, &machine, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []journal.Entry{
+		{Type: journal.Order, Id: "a", OrderStatus: string(orderPlaced)},
+		{Type: journal.Order, Id: "b", OrderStatus: string(orderPlaced)},
+		{Type: journal.Status, Id: "a", OrderStatus: string(orderProcessing)},
+		{Type: journal.Status, Id: "b", OrderStatus: string(orderProcessing)},
+		{Type: journal.Status, Id: "b", OrderStatus: string(orderDispensed), Delta: -1},
+	} {
+		if err := j.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j.Close()
+
+	machine = gumballMachine{CountGumballs: 10}
+	orders = make(map[string]order)
+	j, err = openJournal(dir, 1000, &machine, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if orders["a"].OrderStatus != orderPlaced || orders["b"].OrderStatus != orderDispensed {
+		t.Errorf("orders = %v", orders)
+	}
+	if machine.CountGumballs != 9 {
+		t.Errorf("CountGumballs = %d, want 9", machine.CountGumballs)
+	}
+}
+
+func serve(h http.Handler, method, url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+	return rec
+}
+
+// placeOrder retries POST /order while the queue is full.
+func placeOrder(t *testing.T, url string) order {
+	var ord order
+	for tryRequest(t, "POST", url+"/order", "", &ord) == http.StatusServiceUnavailable {
+		time.Sleep(time.Millisecond)
+	}
+	return ord
+}
+
+func doRequest(t *testing.T, method, url, body string, v interface{}) {
+	if status := tryRequest(t, method, url, body, v); status != http.StatusOK {
+		t.Errorf("%s %s: status %d", method, url, status)
+	}
+}
+
+// tryRequest tolerates the back-pressure responses 429 and 503.
+func tryRequest(t *testing.T, method, url, body string, v interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return resp.StatusCode
+	default:
+		t.Errorf("%s %s: status %d", method, url, resp.StatusCode)
+		return resp.StatusCode
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Errorf("%s %s: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
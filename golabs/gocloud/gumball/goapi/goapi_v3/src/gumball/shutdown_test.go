@@ -0,0 +1,43 @@
+/*
+	Gumball API in Go (Version 3)
+	Process Order with Go Channels
+	Removed Use of Mutex
+*/
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// Draining stops the workers and the state worker for good, so the
+// test runs in a child process of its own.
+func TestDrain(t *testing.T) {
+	if os.Getenv("GUMBALL_DRAIN_TEST") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestDrain$")
+		cmd.Env = append(os.Environ(), "GUMBALL_DRAIN_TEST=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("drain: %v\n%s", err, out)
+		}
+		return
+	}
+
+	for _, id := range []string{"drain-1", "drain-2", "drain-3"} {
+		putOrder(order{Id: id, OrderStatus: orderPlaced})
+		enqueueOrder(id)
+	}
+
+	close(shutdown)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("drain missed its deadline: %v", ctx.Err())
+	}
+}
@@ -6,6 +6,8 @@
 
 package main
 
+import "time"
+
 type gumballMachine struct {
 	Id            int
 	CountGumballs int
@@ -13,18 +15,69 @@ type gumballMachine struct {
 	SerialNumber  string
 }
 
-var machine gumballMachine = gumballMachine{
-	Id:            1,
-	CountGumballs: 900,
-	ModelNumber:   "M102988",
-	SerialNumber:  "1234998871109",
-}
-
 type order struct {
 	Id          string
-	OrderStatus string
+	OrderStatus orderStatus
+	Reason      string `json:",omitempty"`
+}
+
+// State Requests
+// The machine and orders are owned by gumballStateWorker.
+// Other goroutines read or write them by sending one of
+// these requests and waiting on its resp channel.
+type machineReadOp struct {
+	resp chan gumballMachine
+}
+
+// A write that would leave the count below zero is refused
+// and ok is false when resp is sent.
+type machineWriteOp struct {
+	count int
+	delta bool
+	ok    bool
+	resp  chan gumballMachine
+}
+
+type orderReadOp struct {
+	key  string
+	resp chan []order
+}
+
+// With enqueue set the order is only stored if it fits in the
+// order queue; resp is false when the queue is full.
+type orderWriteOp struct {
+	ord     order
+	enqueue bool
+	resp    chan bool
+}
+
+// Queues a placed order that is not already queued.  resp is
+// true when the order was queued; full is set when it was not
+// because the queue is full.
+type orderEnqueueOp struct {
+	key  string
+	full bool
+	resp chan bool
+}
+
+// Moving an order to Dispensed takes a gumball; with none left
+// the order is failed instead.  err is set when resp is sent.
+type orderTransitionOp struct {
+	key  string
+	to   orderStatus
+	err  error
+	resp chan order
 }
 
-var orders map[string]order
-var order_queue = make(chan string, 10)
-var order_update = make(chan int, 1000)
+var machine_reads = make(chan *machineReadOp)
+var machine_writes = make(chan *machineWriteOp)
+var order_reads = make(chan *orderReadOp)
+var order_writes = make(chan *orderWriteOp)
+var order_updates = make(chan *orderTransitionOp)
+var order_enqueues = make(chan *orderEnqueueOp)
+
+// Order Queue (sized by queueConfig)
+// Only gumballStateWorker sends to it.
+var order_queue chan string
+var order_workers int
+var order_process_time = 5000 * time.Millisecond
@@ -0,0 +1,236 @@
+/*
+	Gumball API in Go
+	Append-Only Journal with Snapshots
+*/
+
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+/*
+	Every change to the machine or the orders is appended to
+	journal.log and synced to disk before the API responds.
+	Every JOURNAL_SNAPSHOT entries the full state is written to
+	snapshot.json and the journal is truncated.  On startup the
+	snapshot is loaded and the journal is replayed on top of it.
+
+	Each entry carries a sequence number and the snapshot records
+	the last sequence it includes, so a crash between writing the
+	snapshot and truncating the journal never applies an entry twice.
+
+	The goapi versions each have their own machine and order types;
+	they pass them to Open and Snapshot and apply entries themselves.
+	Build with the shared directory on GOPATH, as the Makefiles do.
+*/
+
+// Entry Types
+const (
+	Order     = "order"
+	Status    = "status"
+	Inventory = "inventory"
+)
+
+type Entry struct {
+	Seq         int64
+	Type        string
+	Id          string `json:",omitempty"`
+	OrderStatus string `json:",omitempty"`
+	Reason      string `json:",omitempty"`
+	Delta       int    `json:",omitempty"`
+}
+
+// Snapshot File
+// Machine and Orders hold the caller's values.
+type snapshot struct {
+	Seq     int64
+	Machine interface{}
+	Orders  interface{}
+}
+
+type Journal struct {
+	mu    sync.Mutex
+	dir   string
+	file  *os.File
+	seq   int64
+	count int
+	every int
+}
+
+// Journal Config from Environment
+// JOURNAL_DIR is where the journal is kept (none when unset) and
+// JOURNAL_SNAPSHOT the number of entries between snapshots.
+func Config() (string, int) {
+	every := 1000
+	if n, err := strconv.Atoi(os.Getenv("JOURNAL_SNAPSHOT")); err == nil && n > 0 {
+		every = n
+	}
+	return os.Getenv("JOURNAL_DIR"), every
+}
+
+// Open Journal and Restore State
+// The snapshot, if there is one, is decoded into machine and
+// orders, which must be pointers; then every entry after it is
+// passed to apply.
+func Open(dir string, every int, machine, orders interface{}, apply func(Entry)) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	j := &Journal{dir: dir, every: every}
+
+	data, err := ioutil.ReadFile(j.path("snapshot.json"))
+	if err == nil {
+		snap := snapshot{Machine: machine, Orders: orders}
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return nil, fmt.Errorf("journal snapshot: %v", err)
+		}
+		j.seq = snap.Seq
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(j.path("journal.log"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := j.replay(f, apply)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// Drop a partial entry left by a crash mid-write
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	j.file = f
+	fmt.Println("Journal Restored: ", j.seq)
+	return j, nil
+}
+
+func (j *Journal) replay(f *os.File, apply func(Entry)) (int64, error) {
+	var valid int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+		var e Entry
+		if json.Unmarshal(line, &e) != nil {
+			return valid, nil
+		}
+		valid += int64(len(line))
+		if e.Seq <= j.seq {
+			continue
+		}
+		apply(e)
+		j.seq = e.Seq
+		j.count++
+	}
+}
+
+// Append Entry and Sync to Disk
+func (j *Journal) Append(e Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e.Seq = j.seq + 1
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.seq = e.Seq
+	j.count++
+	return nil
+}
+
+// Snapshot Needed
+func (j *Journal) Due() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.count >= j.every
+}
+
+// Write Snapshot and Compact Journal
+// The caller must not change machine or orders until it returns.
+func (j *Journal) Snapshot(machine, orders interface{}) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	data, err := json.Marshal(snapshot{Seq: j.seq, Machine: machine, Orders: orders})
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(j.path("snapshot.json"), data); err != nil {
+		return err
+	}
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	j.count = 0
+	fmt.Println("Journal Snapshot: ", j.seq)
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+func (j *Journal) path(name string) string {
+	return filepath.Join(j.dir, name)
+}
+
+func writeFileSync(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	// Persist the rename itself
+	d, err := os.Open(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,141 @@
+/*
+	Gumball API in Go
+	Append-Only Journal with Snapshots
+*/
+
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testMachine struct {
+	CountGumballs int
+}
+
+type testOrder struct {
+	Id          string
+	OrderStatus string
+}
+
+func TestJournalReplay(t *testing.T) {
+	dir := t.TempDir()
+	machine := testMachine{CountGumballs: 900}
+	orders := make(map[string]testOrder)
+
+	j := open(t, dir, 1000, &machine, orders)
+	mustAppend(t, j, Entry{Type: Order, Id: "a", OrderStatus: "Order Placed"})
+	mustAppend(t, j, Entry{Type: Order, Id: "b", OrderStatus: "Order Placed"})
+	mustAppend(t, j, Entry{Type: Status, Id: "a", OrderStatus: "Order Dispensed"})
+	mustAppend(t, j, Entry{Type: Inventory, Delta: -1})
+	j.Close()
+
+	// Simulate a crash in the middle of writing the next entry
+	f, err := os.OpenFile(filepath.Join(dir, "journal.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Seq":5,"Type":"ord`)
+	f.Close()
+
+	machine = testMachine{CountGumballs: 900}
+	orders = make(map[string]testOrder)
+	j = open(t, dir, 1000, &machine, orders)
+	defer j.Close()
+	if machine.CountGumballs != 899 {
+		t.Errorf("CountGumballs = %d, want 899", machine.CountGumballs)
+	}
+	if len(orders) != 2 || orders["a"].OrderStatus != "Order Dispensed" {
+		t.Errorf("orders = %v", orders)
+	}
+	mustAppend(t, j, Entry{Type: Inventory, Delta: -1})
+	if j.seq != 5 {
+		t.Errorf("seq = %d, want 5", j.seq)
+	}
+}
+
+func TestJournalSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	machine := testMachine{CountGumballs: 10}
+	orders := make(map[string]testOrder)
+
+	j := open(t, dir, 2, &machine, orders)
+	for _, id := range []string{"a", "b", "c"} {
+		ord := testOrder{Id: id, OrderStatus: "Order Placed"}
+		orders[id] = ord
+		mustAppend(t, j, Entry{Type: Order, Id: id, OrderStatus: ord.OrderStatus})
+		machine.CountGumballs--
+		mustAppend(t, j, Entry{Type: Inventory, Delta: -1})
+		if j.Due() {
+			if err := j.Snapshot(machine, orders); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	j.Close()
+
+	info, err := os.Stat(filepath.Join(dir, "journal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("journal not compacted, size %d", info.Size())
+	}
+
+	machine = testMachine{CountGumballs: 10}
+	orders = make(map[string]testOrder)
+	j = open(t, dir, 2, &machine, orders)
+	defer j.Close()
+	if machine.CountGumballs != 7 || len(orders) != 3 {
+		t.Errorf("restored %d gumballs and %d orders, want 7 and 3", machine.CountGumballs, len(orders))
+	}
+}
+
+func TestJournalSkipsSnapshottedEntries(t *testing.T) {
+	dir := t.TempDir()
+	machine := testMachine{CountGumballs: 10}
+	orders := make(map[string]testOrder)
+
+	j := open(t, dir, 1000, &machine, orders)
+	mustAppend(t, j, Entry{Type: Inventory, Delta: -1})
+	machine.CountGumballs--
+
+	// Snapshot written but journal not yet truncated
+	data := []byte(`{"Seq":1,"Machine":{"CountGumballs":9},"Orders":{}}`)
+	if err := writeFileSync(filepath.Join(dir, "snapshot.json"), data); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	machine = testMachine{CountGumballs: 10}
+	j = open(t, dir, 1000, &machine, orders)
+	defer j.Close()
+	if machine.CountGumballs != 9 {
+		t.Errorf("CountGumballs = %d, want 9", machine.CountGumballs)
+	}
+}
+
+// Entries are applied as the goapi servers apply them.
+func open(t *testing.T, dir string, every int, machine *testMachine, orders map[string]testOrder) *Journal {
+	j, err := Open(dir, every, machine, &orders, func(e Entry) {
+		switch e.Type {
+		case Order, Status:
+			orders[e.Id] = testOrder{Id: e.Id, OrderStatus: e.OrderStatus}
+			machine.CountGumballs += e.Delta
+		case Inventory:
+			machine.CountGumballs += e.Delta
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func mustAppend(t *testing.T, j *Journal, e Entry) {
+	if err := j.Append(e); err != nil {
+		t.Fatal(err)
+	}
+}
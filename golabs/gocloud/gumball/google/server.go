@@ -43,7 +43,10 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		formatter.JSON(w, http.StatusOK, machine)
+		mutex.Lock()
+		var m = machine
+		mutex.Unlock()
+		formatter.JSON(w, http.StatusOK, m)
 	}
 }
 
@@ -53,8 +56,11 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 		var m gumballMachine
 		_ = json.NewDecoder(req.Body).Decode(&m)
 		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+		mutex.Lock()
 		machine.CountGumballs = m.CountGumballs
-		formatter.JSON(w, http.StatusOK, machine)
+		m = machine
+		mutex.Unlock()
+		formatter.JSON(w, http.StatusOK, m)
 	}
 }
 
@@ -66,11 +72,13 @@ func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 			Id:          uuid.String(),
 			OrderStatus: "Order Placed",
 		}
+		mutex.Lock()
 		if orders == nil {
 			orders = make(map[string]order)
 		}
 		orders[uuid.String()] = ord
 		fmt.Println("Orders: ", orders)
+		mutex.Unlock()
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -82,15 +90,19 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 		var uuid string = params["id"]
 		fmt.Println("Order ID: ", uuid)
 		if uuid == "" {
-			fmt.Println("Orders:", orders)
 			var orders_array []order
+			mutex.Lock()
+			fmt.Println("Orders:", orders)
 			for key, value := range orders {
 				fmt.Println("Key:", key, "Value:", value)
 				orders_array = append(orders_array, value)
 			}
+			mutex.Unlock()
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
+			mutex.Lock()
 			var ord = orders[uuid]
+			mutex.Unlock()
 			fmt.Println("Order: ", ord)
 			formatter.JSON(w, http.StatusOK, ord)
 		}
@@ -100,6 +112,7 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 // API Process Orders
 func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
 		for key, value := range orders {
 			fmt.Println("Key:", key, "Value:", value)
 			var ord = orders[key]
@@ -107,6 +120,7 @@ func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 			orders[key] = ord
 		}
 		fmt.Println("Orders: ", orders)
+		mutex.Unlock()
 		formatter.JSON(w, http.StatusOK, "Orders Processed!")
 	}
 }
@@ -1,5 +1,7 @@
 package main
 
+import "sync"
+
 type gumballMachine struct {
 	Id            int
 	CountGumballs int
@@ -19,4 +21,5 @@ type order struct {
 	OrderStatus string
 }
 
+var mutex = &sync.Mutex{}
 var orders map[string]order
@@ -0,0 +1,122 @@
+/**
+
+Mighty Gumball, Inc.
+Version 7.0
+
+- Machine State Moved to the Go Gumball API (godata/go-gumball)
+- Insert Quarter, Turn Crank and Eject Quarter call the API directly
+- Handlebars Page Templates
+
+NodeJS-Enabled Standing Gumball
+Model# M102988
+Serial# 1234998871109
+
+**/
+
+
+var api = process.env.GUMBALL_API || "http://localhost:3000" ;
+var serial = process.env.SERIAL || "1234998871109" ;
+var machine = api + "/gumball/" + serial ;
+
+
+var fs = require('fs');
+var express = require('express');
+var Client = require('node-rest-client').Client;
+
+var app = express();
+app.use(express.bodyParser());
+app.use("/images", express.static(__dirname + '/images'));
+handlebars  = require('express3-handlebars');
+hbs = handlebars.create();
+app.engine('handlebars', hbs.engine);
+app.set('view engine', 'handlebars');
+
+
+var page = function( req, res, jsdata ) {
+
+    var msg =   "\n\nMighty Gumball, Inc.\n\nNodeJS-Enabled Standing Gumball\n" +
+                "Serial# " + jsdata.SerialNumber + "\n" +
+                "Inventory: " + jsdata.CountGumballs + "\n" +
+                "\nMachine is " + jsdata.State + "\n" +
+                "\n" + jsdata.Message + "\n\n" ;
+
+    res.render('gumball', {
+        message: msg
+    });
+
+}
+
+
+var error = function( req, res, msg ) {
+
+    res.render('gumball', {
+        message: "\n\n*** " + msg + " ***\n"
+    });
+
+}
+
+
+// Call the Go API and render the resulting machine state
+var call = function( req, res, method, url ) {
+
+    var client = new Client();
+    var request = client[method]( url,
+        function(data, response_raw) {
+            console.log(data);
+            jsdata = ( typeof data == "string" ) ? JSON.parse(data) : data ;
+            if ( response_raw.statusCode != 200 )
+                error( req, res, "GUMBALL API ERROR: " + jsdata.Error ) ;
+            else
+                page( req, res, jsdata ) ;
+        });
+    request.on('error', function (err) {
+        console.log( "API Error: " + err ) ;
+        error( req, res, "GUMBALL API UNAVAILABLE" ) ;
+    });
+
+}
+
+
+var handle_post = function (req, res, next) {
+
+    var action = "" + req.body.event ;
+    console.log( "Post: " + "Action: " +  action + "\n" ) ;
+
+    if ( action == "Insert Quarter" )
+        call( req, res, "post", machine + "/quarter" ) ;
+    else if ( action == "Turn Crank" )
+        call( req, res, "post", machine + "/crank" ) ;
+    else if ( action == "Eject Quarter" )
+        call( req, res, "post", machine + "/eject" ) ;
+    else
+        call( req, res, "get", machine + "/state" ) ;
+
+}
+
+var handle_get = function (req, res, next) {
+    console.log( "Get: ..." ) ;
+    call( req, res, "get", machine + "/state" ) ;
+}
+
+
+app.get('/', handle_get ) ;
+app.post('/', handle_post ) ;
+
+
+console.log( "Server running on Port 8080..." ) ;
+
+app.listen(8080);
+
+
+/**
+
+-- Run against a local Go API
+
+cd golabs/godata/go-gumball
+export GOPATH=`pwd`
+make install
+./bin/gumball
+
+GUMBALL_API=http://localhost:3000 node app.js
+
+**/
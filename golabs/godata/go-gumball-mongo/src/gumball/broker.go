@@ -0,0 +1,174 @@
+/*
+	Gumball API in Go (Version 3)
+	RabbitMQ Connection Manager
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+	"sync"
+	"time"
+)
+
+/*
+	One AMQP connection is shared by the publisher and the order
+	consumer.  When RabbitMQ closes it the manager dials again,
+	backing off from 1s up to 30s between attempts.
+
+	Orders and their retries are published on one channel in
+	confirm mode, and publish returns once RabbitMQ has confirmed
+	the message.  While the broker is down publish fails at once;
+	the outbox relay keeps unsent orders in MongoDB and publishes
+	them after reconnecting.  close stops the manager and closes
+	the connection once the workers have drained.
+*/
+
+var errPublishNacked = errors.New("rabbitmq: message not confirmed")
+
+var rabbitmq_confirm_timeout = 5 * time.Second
+
+// Reconnect Backoff
+var rabbitmq_min_backoff = time.Second
+var rabbitmq_max_backoff = 30 * time.Second
+
+// The Part of the Confirm Channel publish Uses
+type amqpChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+type broker struct {
+	url      string
+	mutex    sync.Mutex
+	conn     *amqp.Connection
+	ch       amqpChannel
+	confirms chan amqp.Confirmation
+	ready    chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// Shared RabbitMQ Connection
+var rabbitmq = newBroker(rabbitmqURL())
+
+func newBroker(url string) *broker {
+	return &broker{url: url, ready: make(chan struct{}), done: make(chan struct{})}
+}
+
+// Keep the Connection Open until close
+func (b *broker) run() {
+	backoff := rabbitmq_min_backoff
+	for {
+		conn, err := b.connect()
+		if err != nil {
+			fmt.Println("RabbitMQ Connect Error: ", err, " Retry In: ", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-b.done:
+				return
+			}
+			if backoff *= 2; backoff > rabbitmq_max_backoff {
+				backoff = rabbitmq_max_backoff
+			}
+			continue
+		}
+		backoff = rabbitmq_min_backoff
+		fmt.Println("RabbitMQ Connected: ", rabbitmq_server)
+		select {
+		case err := <-conn.NotifyClose(make(chan *amqp.Error, 1)):
+			fmt.Println("RabbitMQ Connection Closed: ", err)
+			b.disconnect()
+		case <-b.done:
+			conn.Close()
+			return
+		}
+	}
+}
+
+// Stop Reconnecting and Close the Connection
+func (b *broker) close() {
+	b.once.Do(func() { close(b.done) })
+}
+
+// Dial and open the confirm channel
+func (b *broker) connect() (*amqp.Connection, error) {
+	conn, err := amqp.Dial(b.url)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err == nil {
+		err = declareQueues(ch)
+	}
+	if err == nil {
+		err = ch.Confirm(false)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.conn, b.ch = conn, ch
+	b.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	close(b.ready)
+	return conn, nil
+}
+
+func (b *broker) disconnect() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.conn, b.ch, b.confirms = nil, nil, nil
+	b.ready = make(chan struct{})
+}
+
+// Open Connection, Waiting for the Broker if Needed
+// Returns nil once shutdown is closed.
+func (b *broker) connection() *amqp.Connection {
+	for {
+		b.mutex.Lock()
+		conn, ready := b.conn, b.ready
+		b.mutex.Unlock()
+		if conn != nil {
+			return conn
+		}
+		select {
+		case <-ready:
+		case <-shutdown:
+			return nil
+		}
+	}
+}
+
+// Publish to a Queue and Wait for the Confirm
+// Publishes are confirmed one at a time, so the next confirmation
+// on b.confirms is for msg.  Returns amqp.ErrClosed if the message
+// may not have reached the broker; a confirm that times out drops
+// the connection so a late confirmation is never taken for the
+// next message.
+func (b *broker) publish(queue string, msg amqp.Publishing) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.ch == nil {
+		return amqp.ErrClosed
+	}
+	if err := b.ch.Publish("", queue, false, false, msg); err != nil {
+		return amqp.ErrClosed
+	}
+	select {
+	case c, ok := <-b.confirms:
+		if !ok {
+			return amqp.ErrClosed
+		}
+		if !c.Ack {
+			return errPublishNacked
+		}
+		return nil
+	case <-time.After(rabbitmq_confirm_timeout):
+		b.conn.Close()
+		return amqp.ErrClosed
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/streadway/amqp"
+	"testing"
+)
+
+// Use a Connected Broker on a Fake Channel for One Test
+func useFakeBroker(t *testing.T) *fakeChannel {
+	ch := &fakeChannel{confirms: make(chan amqp.Confirmation, 1), ack: true}
+	saved := rabbitmq
+	rabbitmq = newBroker("")
+	rabbitmq.ch, rabbitmq.confirms = ch, ch.confirms
+	t.Cleanup(func() { rabbitmq = saved })
+	return ch
+}
+
+type fakePublish struct {
+	queue string
+	msg   amqp.Publishing
+}
+
+// Confirm Channel Stand-In
+// Every publish is confirmed at once, with an ack or a nack.
+type fakeChannel struct {
+	confirms  chan amqp.Confirmation
+	ack       bool
+	published []fakePublish
+}
+
+func (c *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.published = append(c.published, fakePublish{queue: key, msg: msg})
+	c.confirms <- amqp.Confirmation{DeliveryTag: uint64(len(c.published)), Ack: c.ack}
+	return nil
+}
+
+func TestPublishWaitsForConfirm(t *testing.T) {
+	ch := useFakeBroker(t)
+
+	if err := rabbitmq.publish(rabbitmq_queue, orderMessage("a", 0)); err != nil {
+		t.Fatalf("acked publish: %v", err)
+	}
+	ch.ack = false
+	if err := rabbitmq.publish(rabbitmq_queue, orderMessage("b", 0)); err != errPublishNacked {
+		t.Errorf("nacked publish = %v, want %v", err, errPublishNacked)
+	}
+	if len(ch.published) != 2 || ch.published[0].queue != rabbitmq_queue {
+		t.Errorf("published = %+v", ch.published)
+	}
+
+	// Disconnected
+	rabbitmq.disconnect()
+	if err := rabbitmq.publish(rabbitmq_queue, orderMessage("c", 0)); err != amqp.ErrClosed {
+		t.Errorf("publish while disconnected = %v, want %v", err, amqp.ErrClosed)
+	}
+	if len(ch.published) != 2 {
+		t.Errorf("%d messages published while disconnected", len(ch.published)-2)
+	}
+}
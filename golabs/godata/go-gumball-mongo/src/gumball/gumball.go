@@ -0,0 +1,88 @@
+/*
+	Gumball API in Go (Version 3)
+	MongoDB Gumball Inventory
+*/
+
+package main
+
+import (
+	"errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+/*
+	Inventory is changed in place with guarded updates, never by
+	reading the count and writing back a new one, so concurrent
+	consumers and replicas cannot lose each other's changes.
+	CountGumballs is decoded through the bson tags on
+	gumballMachine, which accepts any numeric BSON type.
+*/
+
+var gumball_serial = "1234998871109"
+
+var errOutOfStock = errors.New("gumball: out of stock")
+
+func getGumball() (gumballMachine, error) {
+	c, err := mongoC(mongodb_collection)
+	if err != nil {
+		return gumballMachine{}, err
+	}
+	var m gumballMachine
+	err = c.FindOne(bson.M{"SerialNumber": gumball_serial}, &m)
+	return m, err
+}
+
+func setGumballCount(count int) (gumballMachine, error) {
+	c, err := mongoC(mongodb_collection)
+	if err != nil {
+		return gumballMachine{}, err
+	}
+	err = c.Update(
+		bson.M{"SerialNumber": gumball_serial},
+		bson.M{"$set": bson.M{"CountGumballs": count}})
+	if err != nil {
+		return gumballMachine{}, err
+	}
+	return getGumball()
+}
+
+// Take One Gumball for an Order, Only While One is Left
+// The order id is added to Dispensing in the same update, and a
+// second take for it is a no-op, so a reclaimed order never takes
+// two gumballs.  Returns errOutOfStock if the decrement was
+// refused.
+func takeGumball(order_id string) error {
+	c, err := mongoC(mongodb_collection)
+	if err != nil {
+		return err
+	}
+	err = c.Update(
+		bson.M{"SerialNumber": gumball_serial, "CountGumballs": bson.M{"$gte": 1}, "Dispensing": bson.M{"$ne": order_id}},
+		bson.M{"$inc": bson.M{"CountGumballs": -1}, "$addToSet": bson.M{"Dispensing": order_id}})
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	// Empty, taken already, or no machine at all
+	m, err := getGumball()
+	if err != nil {
+		return err
+	}
+	for _, id := range m.Dispensing {
+		if id == order_id {
+			return nil
+		}
+	}
+	return errOutOfStock
+}
+
+// Forget a Finished Order's Gumball
+func releaseGumball(order_id string) error {
+	c, err := mongoC(mongodb_collection)
+	if err != nil {
+		return err
+	}
+	return c.Update(
+		bson.M{"SerialNumber": gumball_serial},
+		bson.M{"$pull": bson.M{"Dispensing": order_id}})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTakeGumballNeverBelowZero(t *testing.T) {
+	useFakeMongo(t, 1)
+
+	if err := takeGumball("a"); err != nil {
+		t.Fatalf("take the last gumball: %v", err)
+	}
+	if err := takeGumball("b"); err != errOutOfStock {
+		t.Errorf("take from an empty machine = %v, want %v", err, errOutOfStock)
+	}
+	// A reclaimed order already has its gumball
+	if err := takeGumball("a"); err != nil {
+		t.Errorf("second take for the same order = %v", err)
+	}
+	m, err := getGumball()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CountGumballs != 0 {
+		t.Errorf("CountGumballs = %d, want 0", m.CountGumballs)
+	}
+
+	if err := releaseGumball("a"); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := getGumball(); len(m.Dispensing) != 0 {
+		t.Errorf("Dispensing after release = %v", m.Dispensing)
+	}
+}
+
+func TestProcessOrderOutOfStock(t *testing.T) {
+	useFakeMongo(t, 0)
+	if err := ensureOrder(newOrder("a")); err != nil {
+		t.Fatal(err)
+	}
+	// Failed, not retried
+	if err := processOrder("a"); err != nil {
+		t.Fatalf("processOrder: %v", err)
+	}
+	ord := mustGetOrder(t, "a")
+	if ord.OrderStatus != order_failed || ord.Reason != reason_out_of_stock {
+		t.Errorf("order = %+v, want %q (%s)", ord, order_failed, reason_out_of_stock)
+	}
+	if m, _ := getGumball(); m.CountGumballs != 0 {
+		t.Errorf("CountGumballs = %d, want 0", m.CountGumballs)
+	}
+}
@@ -7,6 +7,8 @@
 package main
 
 import (
+	"log"
+	"net/http"
 	"os"
 )
 
@@ -17,6 +19,14 @@ func main() {
 		port = "3000"
 	}
 
-	server := NewServer()
-	server.Run(":" + port)
+	go rabbitmq.run()
+	workers_done.Add(3)
+	go queue_consume()
+	go outbox_relay()
+	go order_reclaimer()
+
+	srv := &http.Server{Addr: ":" + port, Handler: NewServer()}
+	if err := run(srv); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
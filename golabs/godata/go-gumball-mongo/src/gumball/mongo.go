@@ -0,0 +1,135 @@
+/*
+	Gumball API in Go (Version 3)
+	MongoDB Session
+*/
+
+package main
+
+import (
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"sync"
+)
+
+// MongoDB Orders Collection
+var mongodb_orders = "orders"
+
+// mongoDatabase is the part of MongoDB the service uses.
+// Update returns mgo.ErrNotFound when nothing matches.  FindAll
+// sorts by the sort field unless it is empty and returns at most
+// limit documents unless limit is 0.
+type mongoDatabase interface {
+	C(name string) mongoCollection
+}
+
+type mongoCollection interface {
+	FindOne(query bson.M, result interface{}) error
+	FindAll(query bson.M, sort string, limit int, result interface{}) error
+	Insert(doc interface{}) error
+	Update(selector, change bson.M) error
+	Upsert(selector, change bson.M) error
+	EnsureIndex(index mgo.Index) error
+}
+
+var mongo_db mongoDatabase
+var mongo_mutex sync.Mutex
+
+// Shared MongoDB Database
+// Dials and creates the order and outbox indexes on first use.
+func mongoDB() (mongoDatabase, error) {
+	mongo_mutex.Lock()
+	defer mongo_mutex.Unlock()
+	if mongo_db == nil {
+		session, err := mgo.Dial(mongodb_server)
+		if err != nil {
+			return nil, err
+		}
+		session.SetMode(mgo.Monotonic, true)
+		db := &mgoDatabase{session: session, name: mongodb_database}
+		err = ensureOrderIndexes(db.C(mongodb_orders))
+		if err == nil {
+			err = ensureOutboxIndexes(db.C(mongodb_outbox))
+		}
+		if err != nil {
+			session.Close()
+			return nil, err
+		}
+		mongo_db = db
+	}
+	return mongo_db, nil
+}
+
+// Collection in the Shared Database
+func mongoC(name string) (mongoCollection, error) {
+	db, err := mongoDB()
+	if err != nil {
+		return nil, err
+	}
+	return db.C(name), nil
+}
+
+// mongoDatabase on an mgo Session
+// Each operation runs on its own copy of the session.
+type mgoDatabase struct {
+	session *mgo.Session
+	name    string
+}
+
+type mgoCollection struct {
+	session *mgo.Session
+	db      string
+	name    string
+}
+
+func (d *mgoDatabase) C(name string) mongoCollection {
+	return &mgoCollection{session: d.session, db: d.name, name: name}
+}
+
+func (c *mgoCollection) open() (*mgo.Session, *mgo.Collection) {
+	session := c.session.Copy()
+	return session, session.DB(c.db).C(c.name)
+}
+
+func (c *mgoCollection) FindOne(query bson.M, result interface{}) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Find(query).One(result)
+}
+
+func (c *mgoCollection) FindAll(query bson.M, sort string, limit int, result interface{}) error {
+	session, coll := c.open()
+	defer session.Close()
+	q := coll.Find(query)
+	if sort != "" {
+		q = q.Sort(sort)
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	return q.All(result)
+}
+
+func (c *mgoCollection) Insert(doc interface{}) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Insert(doc)
+}
+
+func (c *mgoCollection) Update(selector, change bson.M) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Update(selector, change)
+}
+
+func (c *mgoCollection) Upsert(selector, change bson.M) error {
+	session, coll := c.open()
+	defer session.Close()
+	_, err := coll.Upsert(selector, change)
+	return err
+}
+
+func (c *mgoCollection) EnsureIndex(index mgo.Index) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.EnsureIndex(index)
+}
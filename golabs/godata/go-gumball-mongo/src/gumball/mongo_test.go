@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Use a Fake MongoDB for One Test
+// The gumball collection starts with one machine holding count
+// gumballs.
+func useFakeMongo(t *testing.T, count int) *fakeMongo {
+	db := &fakeMongo{collections: make(map[string][]bson.M)}
+	m := gumballMachine{Id: 1, CountGumballs: count, ModelNumber: "M102988", SerialNumber: gumball_serial}
+	if err := db.C(mongodb_collection).Insert(m); err != nil {
+		t.Fatal(err)
+	}
+	mongo_mutex.Lock()
+	saved := mongo_db
+	mongo_db = db
+	mongo_mutex.Unlock()
+	t.Cleanup(func() {
+		mongo_mutex.Lock()
+		mongo_db = saved
+		mongo_mutex.Unlock()
+	})
+	return db
+}
+
+func mustGetOrder(t *testing.T, id string) order {
+	t.Helper()
+	ord, err := getOrder(id)
+	if err != nil {
+		t.Fatalf("getOrder(%s): %v", id, err)
+	}
+	return ord
+}
+
+// MongoDB Stand-In
+// Documents are kept as bson.M.  Queries support equality, $ne
+// (which matches no element of an array) and $gte/$lt; updates
+// support $set, $inc, $setOnInsert, $addToSet and $pull.
+type fakeMongo struct {
+	mutex       sync.Mutex
+	collections map[string][]bson.M
+}
+
+type fakeCollection struct {
+	db   *fakeMongo
+	name string
+}
+
+func (d *fakeMongo) C(name string) mongoCollection {
+	return &fakeCollection{db: d, name: name}
+}
+
+func (c *fakeCollection) FindOne(query bson.M, result interface{}) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, query) {
+			return convertDoc(doc, result)
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func (c *fakeCollection) FindAll(query bson.M, sortKey string, limit int, result interface{}) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	var docs []bson.M
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, query) {
+			docs = append(docs, doc)
+		}
+	}
+	if sortKey != "" {
+		sort.SliceStable(docs, func(i, j int) bool {
+			n, _ := compare(docs[i][sortKey], docs[j][sortKey])
+			return n < 0
+		})
+	}
+	if limit > 0 && len(docs) > limit {
+		docs = docs[:limit]
+	}
+	slice := reflect.ValueOf(result).Elem()
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, len(docs)))
+	for _, doc := range docs {
+		elem := reflect.New(slice.Type().Elem())
+		if err := convertDoc(doc, elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+	return nil
+}
+
+func (c *fakeCollection) Insert(doc interface{}) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	var m bson.M
+	if err := convertDoc(doc, &m); err != nil {
+		return err
+	}
+	c.db.collections[c.name] = append(c.db.collections[c.name], m)
+	return nil
+}
+
+func (c *fakeCollection) Update(selector, change bson.M) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, selector) {
+			return applyChange(doc, change, false)
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func (c *fakeCollection) Upsert(selector, change bson.M) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, selector) {
+			return applyChange(doc, change, false)
+		}
+	}
+	doc := bson.M{}
+	for key, value := range selector {
+		if _, ok := value.(bson.M); !ok {
+			doc[key] = value
+		}
+	}
+	if err := applyChange(doc, change, true); err != nil {
+		return err
+	}
+	c.db.collections[c.name] = append(c.db.collections[c.name], doc)
+	return nil
+}
+
+func (c *fakeCollection) EnsureIndex(index mgo.Index) error {
+	return nil
+}
+
+func convertDoc(in, out interface{}) error {
+	data, err := bson.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, out)
+}
+
+func matchDoc(doc, query bson.M) bool {
+	for key, want := range query {
+		got, ok := doc[key]
+		ops, isOps := want.(bson.M)
+		if !isOps {
+			if !ok || !equalValue(got, want) {
+				return false
+			}
+			continue
+		}
+		for op, arg := range ops {
+			if op == "$ne" {
+				if ok && containsValue(got, arg) {
+					return false
+				}
+				continue
+			}
+			n, comparable := compare(got, arg)
+			if !ok || !comparable {
+				return false
+			}
+			switch op {
+			case "$gte":
+				ok = n >= 0
+			case "$lt":
+				ok = n < 0
+			default:
+				panic("fake mongo: unsupported operator " + op)
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func applyChange(doc, change bson.M, insert bool) error {
+	for op, arg := range change {
+		var fields bson.M
+		if err := convertDoc(arg, &fields); err != nil {
+			return err
+		}
+		switch op {
+		case "$set":
+			for key, value := range fields {
+				doc[key] = value
+			}
+		case "$setOnInsert":
+			if insert {
+				for key, value := range fields {
+					doc[key] = value
+				}
+			}
+		case "$inc":
+			for key, value := range fields {
+				x, y, ok := numbers(doc[key], value)
+				if doc[key] == nil {
+					x, ok = 0, true
+				}
+				if !ok {
+					return fmt.Errorf("fake mongo: $inc on non-numeric %s", key)
+				}
+				doc[key] = int(x + y)
+			}
+		case "$addToSet":
+			for key, value := range fields {
+				items, _ := doc[key].([]interface{})
+				if !containsValue(items, value) {
+					doc[key] = append(items, value)
+				}
+			}
+		case "$pull":
+			for key, value := range fields {
+				items, _ := doc[key].([]interface{})
+				var kept []interface{}
+				for _, item := range items {
+					if !equalValue(item, value) {
+						kept = append(kept, item)
+					}
+				}
+				doc[key] = kept
+			}
+		default:
+			panic("fake mongo: unsupported update " + op)
+		}
+	}
+	return nil
+}
+
+// An array contains value if any element equals it
+func containsValue(field, value interface{}) bool {
+	items, ok := field.([]interface{})
+	if !ok {
+		return equalValue(field, value)
+	}
+	for _, item := range items {
+		if equalValue(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalValue(a, b interface{}) bool {
+	if n, ok := compare(a, b); ok {
+		return n == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Order Numbers, Strings and Times
+func compare(a, b interface{}) (int, bool) {
+	if x, y, ok := numbers(a, b); ok {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1, true
+			case x.After(y):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+func numbers(a, b interface{}) (float64, float64, bool) {
+	x, ok1 := number(a)
+	y, ok2 := number(b)
+	return x, y, ok1 && ok2
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
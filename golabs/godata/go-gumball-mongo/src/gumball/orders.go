@@ -0,0 +1,216 @@
+/*
+	Gumball API in Go (Version 3)
+	MongoDB Order Storage
+*/
+
+package main
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"sort"
+	"time"
+)
+
+/*
+	Orders are documents in the orders collection, so every
+	replica behind Kong sees the same orders and they survive a
+	restart.  The queue consumer moves an order through
+
+		Order Placed -> Order Processing -> Order Processed
+		                                 -> Order Failed (Out of Stock)
+
+	with conditional updates, so an order delivered twice is only
+	processed once.  Claiming an order stamps ProcessingAt, and
+	only the holder of that stamp may finish it.  An order left in
+	Order Processing by a crash is reclaimed by order_reclaimer
+	once its stamp is order_lease old.  The gumball is taken at
+	most once per order (see takeGumball), so a reclaimed order
+	that already has its gumball is just marked processed.
+*/
+
+// Order Status Values
+const (
+	order_placed     = "Order Placed"
+	order_processing = "Order Processing"
+	order_processed  = "Order Processed"
+	order_failed     = "Order Failed"
+)
+
+// Failed Order Reasons
+const reason_out_of_stock = "Out of Stock"
+
+// Age of a Claim Before its Order is Reclaimed
+var order_lease = 30 * time.Second
+
+func ensureOrderIndexes(c mongoCollection) error {
+	indexes := []mgo.Index{
+		{Key: []string{"Id"}, Unique: true},
+		{Key: []string{"OrderStatus", "ProcessingAt"}},
+		{Key: []string{"CreatedAt"}},
+	}
+	for _, index := range indexes {
+		if err := c.EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert the Order Unless it Already Exists
+func ensureOrder(ord order) error {
+	c, err := mongoC(mongodb_orders)
+	if err != nil {
+		return err
+	}
+	return c.Upsert(
+		bson.M{"Id": ord.Id},
+		bson.M{"$setOnInsert": ord})
+}
+
+// Returns mgo.ErrNotFound for an unknown order.
+// An order still waiting in the outbox is read from its entry.
+func getOrder(id string) (order, error) {
+	db, err := mongoDB()
+	if err != nil {
+		return order{}, err
+	}
+	var ord order
+	err = db.C(mongodb_orders).FindOne(bson.M{"Id": id}, &ord)
+	if err != mgo.ErrNotFound {
+		return ord, err
+	}
+	var entry outboxEntry
+	err = db.C(mongodb_outbox).FindOne(bson.M{"Id": id, "Status": outbox_pending}, &entry)
+	if err != mgo.ErrNotFound {
+		return entry.Order, err
+	}
+	// Sent in between; the order document is created first
+	err = db.C(mongodb_orders).FindOne(bson.M{"Id": id}, &ord)
+	return ord, err
+}
+
+// Orders Oldest First
+// Includes orders still waiting in the outbox.
+func listOrders() ([]order, error) {
+	db, err := mongoDB()
+	if err != nil {
+		return nil, err
+	}
+	orders_array := []order{}
+	err = db.C(mongodb_orders).FindAll(nil, "CreatedAt", 0, &orders_array)
+	if err != nil {
+		return nil, err
+	}
+	var pending []outboxEntry
+	err = db.C(mongodb_outbox).FindAll(bson.M{"Status": outbox_pending}, "", 0, &pending)
+	if err != nil || len(pending) == 0 {
+		return orders_array, err
+	}
+	// A pending entry may already have its order document
+	known := make(map[string]bool)
+	for _, ord := range orders_array {
+		known[ord.Id] = true
+	}
+	for _, entry := range pending {
+		if !known[entry.Id] {
+			orders_array = append(orders_array, entry.Order)
+		}
+	}
+	sort.SliceStable(orders_array, func(i, j int) bool {
+		return orders_array[i].CreatedAt.Before(orders_array[j].CreatedAt)
+	})
+	return orders_array, nil
+}
+
+// Claim a Placed Order for Processing
+// Returns the claim's ProcessingAt, or false if the order is not
+// placed.
+func claimOrder(id string) (time.Time, bool, error) {
+	return leaseOrder(bson.M{"Id": id, "OrderStatus": order_placed})
+}
+
+// Take Over an Order whose Claim Expired
+func reclaimOrder(ord order) (time.Time, bool, error) {
+	return leaseOrder(bson.M{"Id": ord.Id, "OrderStatus": order_processing, "ProcessingAt": ord.ProcessingAt})
+}
+
+func leaseOrder(query bson.M) (time.Time, bool, error) {
+	c, err := mongoC(mongodb_orders)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	// Stored to the millisecond
+	lease := time.Now().UTC().Truncate(time.Millisecond)
+	err = c.Update(
+		query,
+		bson.M{"$set": bson.M{"OrderStatus": order_processing, "ProcessingAt": lease}})
+	if err == mgo.ErrNotFound {
+		return time.Time{}, false, nil
+	}
+	return lease, err == nil, err
+}
+
+// Finish an Order Still Held by the Claim at lease
+// Returns false if the claim was lost to a reclaim.
+func finishOrder(id string, lease time.Time, status, reason string) (bool, error) {
+	c, err := mongoC(mongodb_orders)
+	if err != nil {
+		return false, err
+	}
+	err = c.Update(
+		bson.M{"Id": id, "OrderStatus": order_processing, "ProcessingAt": lease},
+		bson.M{"$set": bson.M{"OrderStatus": status, "Reason": reason}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Orders Claimed Longer than order_lease Ago
+func staleOrders() ([]order, error) {
+	c, err := mongoC(mongodb_orders)
+	if err != nil {
+		return nil, err
+	}
+	var orders_array []order
+	err = c.FindAll(
+		bson.M{"OrderStatus": order_processing, "ProcessingAt": bson.M{"$lt": time.Now().UTC().Add(-order_lease)}},
+		"", outbox_batch, &orders_array)
+	return orders_array, err
+}
+
+// Finish Stale Orders until Shutdown
+func order_reclaimer() {
+	defer workers_done.Done()
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-time.After(order_lease / 2):
+		}
+		stale, err := staleOrders()
+		if err != nil {
+			fmt.Println("Order Reclaim Error: ", err)
+			continue
+		}
+		for _, ord := range stale {
+			if stopping() {
+				return
+			}
+			lease, claimed, err := reclaimOrder(ord)
+			if err != nil || !claimed {
+				continue
+			}
+			fmt.Println("Reclaimed Order: ", ord.Id)
+			if err := dispenseOrder(ord.Id, lease); err != nil {
+				fmt.Println("Order Failed: ", ord.Id, err)
+			}
+		}
+	}
+}
+
+func newOrder(id string) order {
+	return order{Id: id, OrderStatus: order_placed, CreatedAt: time.Now().UTC()}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaimOrderOnce(t *testing.T) {
+	useFakeMongo(t, 1)
+	if err := ensureOrder(newOrder("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	lease, claimed, err := claimOrder("a")
+	if err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v", claimed, err)
+	}
+	if ord := mustGetOrder(t, "a"); ord.OrderStatus != order_processing || !ord.ProcessingAt.Equal(lease) {
+		t.Errorf("claimed order = %+v, want %q at %v", ord, order_processing, lease)
+	}
+	if _, claimed, err := claimOrder("a"); err != nil || claimed {
+		t.Errorf("second claim = %v, %v, want refused", claimed, err)
+	}
+	if _, claimed, err := claimOrder("unknown"); err != nil || claimed {
+		t.Errorf("claim of unknown order = %v, %v, want refused", claimed, err)
+	}
+}
+
+func TestFinishOrderNeedsItsClaim(t *testing.T) {
+	useFakeMongo(t, 1)
+	if err := ensureOrder(newOrder("a")); err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := claimOrder("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The claim expires and the reclaimer takes the order over
+	time.Sleep(2 * time.Millisecond)
+	second, claimed, err := reclaimOrder(mustGetOrder(t, "a"))
+	if err != nil || !claimed {
+		t.Fatalf("reclaim = %v, %v", claimed, err)
+	}
+	if _, claimed, err := reclaimOrder(order{Id: "a", ProcessingAt: first}); err != nil || claimed {
+		t.Errorf("reclaim with the old claim = %v, %v, want refused", claimed, err)
+	}
+
+	if finished, err := finishOrder("a", first, order_processed, ""); err != nil || finished {
+		t.Errorf("finish with the lost claim = %v, %v, want refused", finished, err)
+	}
+	if ord := mustGetOrder(t, "a"); ord.OrderStatus != order_processing {
+		t.Errorf("status after refused finish = %q", ord.OrderStatus)
+	}
+	if finished, err := finishOrder("a", second, order_processed, ""); err != nil || !finished {
+		t.Fatalf("finish with the current claim = %v, %v", finished, err)
+	}
+	if ord := mustGetOrder(t, "a"); ord.OrderStatus != order_processed {
+		t.Errorf("status after finish = %q, want %q", ord.OrderStatus, order_processed)
+	}
+	if finished, err := finishOrder("a", second, order_failed, reason_out_of_stock); err != nil || finished {
+		t.Errorf("second finish = %v, %v, want refused", finished, err)
+	}
+}
+
+func TestProcessOrderDeliveredTwice(t *testing.T) {
+	useFakeMongo(t, 5)
+	if err := ensureOrder(newOrder("a")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := processOrder("a"); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if ord := mustGetOrder(t, "a"); ord.OrderStatus != order_processed {
+		t.Errorf("status = %q, want %q", ord.OrderStatus, order_processed)
+	}
+	if m, _ := getGumball(); m.CountGumballs != 4 || len(m.Dispensing) != 0 {
+		t.Errorf("machine = %+v, want 4 gumballs and none dispensing", m)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+	Gumball API in Go (Version 3)
+	MongoDB Order Outbox
+*/
+
+package main
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+/*
+	A new order is first written to the outbox collection, a
+	single insert, so the order is accepted once that write
+	succeeds.  The order document is created from the outbox
+	entry before the order is published, then the entry is marked
+	sent.  POST /order tries this right away; the relay retries
+	pending entries every outbox_interval, so an order is
+	published at least once even if the process dies or RabbitMQ
+	is down.  Duplicate messages are harmless because the consumer
+	claims each order only once.  Until its order document exists,
+	GET /order serves an order from its pending entry.
+
+	Sent entries are removed by MongoDB after a day.
+*/
+
+// MongoDB Outbox Collection
+var mongodb_outbox = "outbox"
+
+// Outbox Entry Status
+const (
+	outbox_pending = "Pending"
+	outbox_sent    = "Sent"
+)
+
+var outbox_interval = time.Second
+var outbox_batch = 100
+
+// Wakes the relay early after a failed publish
+var outbox_wake = make(chan struct{}, 1)
+
+type outboxEntry struct {
+	Id        string    `bson:"Id"`
+	Order     order     `bson:"Order"`
+	Status    string    `bson:"Status"`
+	CreatedAt time.Time `bson:"CreatedAt"`
+	SentAt    time.Time `bson:"SentAt,omitempty"`
+}
+
+func ensureOutboxIndexes(c mongoCollection) error {
+	indexes := []mgo.Index{
+		{Key: []string{"Id"}, Unique: true},
+		{Key: []string{"Status", "CreatedAt"}},
+		{Key: []string{"SentAt"}, ExpireAfter: 24 * time.Hour},
+	}
+	for _, index := range indexes {
+		if err := c.EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Record a New Order in the Outbox
+func insertOutbox(ord order) (outboxEntry, error) {
+	entry := outboxEntry{Id: ord.Id, Order: ord, Status: outbox_pending, CreatedAt: ord.CreatedAt}
+	c, err := mongoC(mongodb_outbox)
+	if err != nil {
+		return outboxEntry{}, err
+	}
+	return entry, c.Insert(entry)
+}
+
+// Create the Order, Publish it and Mark the Entry Sent
+func sendOutbox(entry outboxEntry) error {
+	if err := ensureOrder(entry.Order); err != nil {
+		return err
+	}
+	if err := queue_send(entry.Id); err != nil {
+		return err
+	}
+	c, err := mongoC(mongodb_outbox)
+	if err != nil {
+		return err
+	}
+	err = c.Update(
+		bson.M{"Id": entry.Id, "Status": outbox_pending},
+		bson.M{"$set": bson.M{"Status": outbox_sent, "SentAt": time.Now().UTC()}})
+	if err == mgo.ErrNotFound {
+		// Sent by another relay
+		return nil
+	}
+	return err
+}
+
+func pendingOutbox() ([]outboxEntry, error) {
+	c, err := mongoC(mongodb_outbox)
+	if err != nil {
+		return nil, err
+	}
+	var entries []outboxEntry
+	err = c.FindAll(bson.M{"Status": outbox_pending}, "CreatedAt", outbox_batch, &entries)
+	return entries, err
+}
+
+// Publish Pending Orders until Shutdown
+func outbox_relay() {
+	defer workers_done.Done()
+	for {
+		select {
+		case <-outbox_wake:
+		case <-time.After(outbox_interval):
+		case <-shutdown:
+			return
+		}
+		entries, err := pendingOutbox()
+		if err != nil {
+			fmt.Println("Outbox Error: ", err)
+			continue
+		}
+		for _, entry := range entries {
+			if stopping() {
+				return
+			}
+			if err := sendOutbox(entry); err != nil {
+				fmt.Println("Outbox Publish Error: ", entry.Id, err)
+				break
+			}
+			fmt.Println("Outbox Sent: ", entry.Id)
+		}
+	}
+}
+
+// Ask the relay to retry soon
+func wakeOutbox() {
+	select {
+	case outbox_wake <- struct{}{}:
+	default:
+	}
+}
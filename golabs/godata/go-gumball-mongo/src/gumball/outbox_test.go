@@ -0,0 +1,79 @@
+package main
+
+import (
+	"gopkg.in/mgo.v2/bson"
+	"testing"
+)
+
+func outboxStatus(t *testing.T, db *fakeMongo, id string) outboxEntry {
+	t.Helper()
+	var entry outboxEntry
+	if err := db.C(mongodb_outbox).FindOne(bson.M{"Id": id}, &entry); err != nil {
+		t.Fatalf("outbox entry %s: %v", id, err)
+	}
+	return entry
+}
+
+func TestSendOutboxMarksSentOnlyAfterConfirm(t *testing.T) {
+	db := useFakeMongo(t, 1)
+	ch := useFakeBroker(t)
+	entry, err := insertOutbox(newOrder("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Broker down
+	rabbitmq.disconnect()
+	if err := sendOutbox(entry); err == nil {
+		t.Error("sendOutbox while disconnected succeeded")
+	}
+	if e := outboxStatus(t, db, "a"); e.Status != outbox_pending {
+		t.Errorf("status after failed publish = %q, want %q", e.Status, outbox_pending)
+	}
+
+	// Nacked
+	rabbitmq.ch, rabbitmq.confirms = ch, ch.confirms
+	ch.ack = false
+	if err := sendOutbox(entry); err != errPublishNacked {
+		t.Errorf("sendOutbox when nacked = %v, want %v", err, errPublishNacked)
+	}
+	if e := outboxStatus(t, db, "a"); e.Status != outbox_pending {
+		t.Errorf("status after nack = %q, want %q", e.Status, outbox_pending)
+	}
+	if pending, err := pendingOutbox(); err != nil || len(pending) != 1 {
+		t.Errorf("pending after nack = %+v, %v", pending, err)
+	}
+
+	ch.ack = true
+	if err := sendOutbox(entry); err != nil {
+		t.Fatalf("sendOutbox when acked: %v", err)
+	}
+	if e := outboxStatus(t, db, "a"); e.Status != outbox_sent || e.SentAt.IsZero() {
+		t.Errorf("entry after confirm = %+v, want %q with SentAt", e, outbox_sent)
+	}
+	if pending, err := pendingOutbox(); err != nil || len(pending) != 0 {
+		t.Errorf("pending after confirm = %+v, %v", pending, err)
+	}
+	for _, p := range ch.published {
+		if p.queue != rabbitmq_queue || string(p.msg.Body) != "a" {
+			t.Errorf("published %s to %s, want a to %s", p.msg.Body, p.queue, rabbitmq_queue)
+		}
+	}
+	if len(ch.published) != 2 {
+		t.Errorf("%d publishes, want 2", len(ch.published))
+	}
+}
+
+func TestGetOrderFromPendingOutbox(t *testing.T) {
+	useFakeMongo(t, 1)
+	if _, err := insertOutbox(newOrder("a")); err != nil {
+		t.Fatal(err)
+	}
+	if ord := mustGetOrder(t, "a"); ord.Id != "a" || ord.OrderStatus != order_placed {
+		t.Errorf("pending order = %+v", ord)
+	}
+	orders_array, err := listOrders()
+	if err != nil || len(orders_array) != 1 {
+		t.Errorf("listOrders = %+v, %v", orders_array, err)
+	}
+}
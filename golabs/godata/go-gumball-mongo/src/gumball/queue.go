@@ -0,0 +1,228 @@
+/*
+	Gumball API in Go (Version 3)
+	RabbitMQ Order Queue
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+	Orders are published as persistent messages to the durable
+	gumball queue.  One consumer runs for the life of the process
+	and processes each order as it arrives, acking it only once
+	it has been processed.
+
+	An order that fails is published to the gumball.retry queue
+	with its x-retries header incremented, and acked once RabbitMQ
+	has confirmed the copy.  Nothing consumes gumball.retry: the
+	copy expires there after rabbitmq_retry_ttl and is dead-lettered
+	back to the gumball queue.  After rabbitmq_max_retries attempts
+	the order is rejected and RabbitMQ dead-letters it through the
+	gumball.dlx exchange to the gumball.dead queue for inspection.
+
+	Environment:
+		RABBITMQ_PREFETCH	unacked orders per consumer (default 10)
+		RABBITMQ_RETRIES	attempts before dead-lettering (default 3)
+		RABBITMQ_RETRY_DELAY	wait before an order is retried (default 5s)
+
+	A gumball queue created as non-durable by an older version
+	must be deleted before the new declaration is accepted.
+	The connection is shared through the manager in broker.go.
+*/
+
+// Dead Letter Config
+var rabbitmq_dlx = "gumball.dlx"
+var rabbitmq_dead_queue = "gumball.dead"
+
+// Failed Orders Wait Here before their Next Attempt
+var rabbitmq_retry_queue = "gumball.retry"
+var rabbitmq_retry_ttl = envDuration("RABBITMQ_RETRY_DELAY", 5*time.Second)
+
+var rabbitmq_prefetch = envInt("RABBITMQ_PREFETCH", 10)
+var rabbitmq_max_retries = envInt("RABBITMQ_RETRIES", 3)
+
+// Wait before opening a new consumer channel
+var rabbitmq_retry_delay = time.Second
+
+func envInt(key string, value int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return value
+}
+
+func envDuration(key string, value time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return value
+}
+
+func rabbitmqURL() string {
+	return "amqp://" + rabbitmq_user + ":" + rabbitmq_pass + "@" + rabbitmq_server + ":" + rabbitmq_port + "/"
+}
+
+// Declare the Order Queue, its Retry Queue and its Dead Letter Queue
+func declareQueues(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(
+		rabbitmq_dlx, // name
+		"fanout",     // kind
+		true,         // durable
+		false,        // delete when unused
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return err
+	}
+	dead, err := ch.QueueDeclare(rabbitmq_dead_queue, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	if err := ch.QueueBind(dead.Name, "", rabbitmq_dlx, false, nil); err != nil {
+		return err
+	}
+	_, err = ch.QueueDeclare(
+		rabbitmq_queue, // name
+		true,           // durable
+		false,          // delete when unused
+		false,          // exclusive
+		false,          // no-wait
+		amqp.Table{"x-dead-letter-exchange": rabbitmq_dlx}, // arguments
+	)
+	if err != nil {
+		return err
+	}
+	// Expired retries go back to the order queue
+	_, err = ch.QueueDeclare(rabbitmq_retry_queue, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": rabbitmq_queue,
+	})
+	return err
+}
+
+// Persistent Order Message
+func orderMessage(order_id string, retries int) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType:  "text/plain",
+		DeliveryMode: amqp.Persistent,
+		Headers:      amqp.Table{"x-retries": int32(retries)},
+		Body:         []byte(order_id),
+	}
+}
+
+// Send Order to Queue for Processing
+// Returns an error unless RabbitMQ confirmed the message.
+func queue_send(message string) error {
+	err := rabbitmq.publish(rabbitmq_queue, orderMessage(message, 0))
+	if err == nil {
+		log.Printf(" [x] Sent %s", message)
+	}
+	return err
+}
+
+// Consume Orders until Shutdown
+// Waits for the connection manager to reconnect if the broker
+// goes away.
+func queue_consume() {
+	defer workers_done.Done()
+	for {
+		conn := rabbitmq.connection()
+		if conn == nil {
+			return
+		}
+		if err := consumeOrders(conn); err != nil {
+			fmt.Println("RabbitMQ Consumer Error: ", err)
+		}
+		select {
+		case <-shutdown:
+			return
+		case <-time.After(rabbitmq_retry_delay):
+		}
+	}
+}
+
+func consumeOrders(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	if err := declareQueues(ch); err != nil {
+		return err
+	}
+	if err := ch.Qos(rabbitmq_prefetch, 0, false); err != nil {
+		return err
+	}
+	msgs, err := ch.Consume(
+		rabbitmq_queue, // queue
+		"orders",       // consumer
+		false,          // auto-ack
+		false,          // exclusive
+		false,          // no-local
+		false,          // no-wait
+		nil,            // args
+	)
+	if err != nil {
+		return err
+	}
+
+	for {
+		var d amqp.Delivery
+		var ok bool
+		select {
+		case d, ok = <-msgs:
+		case <-shutdown:
+			// Closing the channel requeues prefetched orders
+			return nil
+		}
+		if !ok {
+			return errors.New("consumer channel closed")
+		}
+		order_id := string(d.Body)
+		log.Printf("Received a message: %s", order_id)
+		if err := processOrder(order_id); err != nil {
+			fmt.Println("Order Failed: ", order_id, err)
+			if err := retryOrder(d); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.Ack(false); err != nil {
+			return err
+		}
+	}
+}
+
+// Publish a Failed Order to the Retry Queue, or Dead-Letter it
+// The delivery is only acked once the retry is confirmed.
+func retryOrder(d amqp.Delivery) error {
+	retries := 0
+	if n, ok := d.Headers["x-retries"].(int32); ok {
+		retries = int(n)
+	}
+	retries++
+	if retries >= rabbitmq_max_retries {
+		fmt.Println("Dead-Lettering Order: ", string(d.Body))
+		return d.Nack(false, false)
+	}
+	msg := orderMessage(string(d.Body), retries)
+	msg.Expiration = strconv.FormatInt(int64(rabbitmq_retry_ttl/time.Millisecond), 10)
+	if err := rabbitmq.publish(rabbitmq_retry_queue, msg); err != nil {
+		// Leave the order on the queue for the next consumer
+		d.Nack(false, true)
+		return err
+	}
+	return d.Ack(false)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"github.com/streadway/amqp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Records what the consumer did with a delivery
+type fakeAcknowledger struct {
+	acked    bool
+	nacked   bool
+	requeued bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func failedDelivery(retries int) (amqp.Delivery, *fakeAcknowledger) {
+	ack := &fakeAcknowledger{}
+	return amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      amqp.Table{"x-retries": int32(retries)},
+		Body:         []byte("a"),
+	}, ack
+}
+
+func TestRetryOrderWaitsInRetryQueue(t *testing.T) {
+	ch := useFakeBroker(t)
+	d, ack := failedDelivery(0)
+
+	if err := retryOrder(d); err != nil {
+		t.Fatal(err)
+	}
+	if !ack.acked || ack.nacked {
+		t.Errorf("delivery = %+v, want acked", ack)
+	}
+	if len(ch.published) != 1 {
+		t.Fatalf("%d publishes, want 1", len(ch.published))
+	}
+	p := ch.published[0]
+	if p.queue != rabbitmq_retry_queue || string(p.msg.Body) != "a" {
+		t.Errorf("published %s to %s, want a to %s", p.msg.Body, p.queue, rabbitmq_retry_queue)
+	}
+	if n := p.msg.Headers["x-retries"]; n != int32(1) {
+		t.Errorf("x-retries = %v, want 1", n)
+	}
+	if want := strconv.FormatInt(int64(rabbitmq_retry_ttl/time.Millisecond), 10); p.msg.Expiration != want {
+		t.Errorf("Expiration = %q, want %q", p.msg.Expiration, want)
+	}
+}
+
+func TestRetryOrderAcksOnlyAfterConfirm(t *testing.T) {
+	ch := useFakeBroker(t)
+	ch.ack = false
+	d, ack := failedDelivery(0)
+
+	if err := retryOrder(d); err != errPublishNacked {
+		t.Errorf("retryOrder = %v, want %v", err, errPublishNacked)
+	}
+	if ack.acked || !ack.nacked || !ack.requeued {
+		t.Errorf("delivery = %+v, want requeued", ack)
+	}
+}
+
+func TestRetryOrderDeadLettersLastAttempt(t *testing.T) {
+	ch := useFakeBroker(t)
+	d, ack := failedDelivery(rabbitmq_max_retries - 1)
+
+	if err := retryOrder(d); err != nil {
+		t.Fatal(err)
+	}
+	if ack.acked || !ack.nacked || ack.requeued {
+		t.Errorf("delivery = %+v, want rejected", ack)
+	}
+	if len(ch.published) != 0 {
+		t.Errorf("%d publishes for a dead-lettered order", len(ch.published))
+	}
+}
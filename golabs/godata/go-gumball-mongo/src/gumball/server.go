@@ -8,16 +8,14 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"encoding/json"
 	"github.com/codegangsta/negroni"
-	"github.com/streadway/amqp"
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
 	"github.com/satori/go.uuid"
 	"gopkg.in/mgo.v2"
-    "gopkg.in/mgo.v2/bson"
+	"time"
 )
 
 // MongoDB Config
@@ -55,14 +53,6 @@ func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/orders", gumballProcessOrdersHandler(formatter)).Methods("POST")
 }
 
-// Helper Functions
-func failOnError(err error, msg string) {
-	if err != nil {
-		log.Fatalf("%s: %s", msg, err)
-		panic(fmt.Sprintf("%s: %s", msg, err))
-	}
-}
-
 // API Ping Handler
 func pingHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
@@ -73,19 +63,12 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		session, err := mgo.Dial(mongodb_server)
-        if err != nil {
-                panic(err)
-        }
-        defer session.Close()
-        session.SetMode(mgo.Monotonic, true)
-        c := session.DB(mongodb_database).C(mongodb_collection)
-        var result bson.M
-        err = c.Find(bson.M{"SerialNumber" : "1234998871109"}).One(&result)
-        if err != nil {
-                log.Fatal(err)
-        }
-        fmt.Println("Gumball Machine:", result )
+		result, err := getGumball()
+		if err != nil {
+			mongoErrorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Gumball Machine:", result)
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
@@ -93,46 +76,50 @@ func gumballHandler(formatter *render.Render) http.HandlerFunc {
 // API Update Gumball Inventory
 func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-    	var m gumballMachine
-    	_ = json.NewDecoder(req.Body).Decode(&m)		
-    	fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
-		session, err := mgo.Dial(mongodb_server)
-        if err != nil {
-                panic(err)
-        }
-        defer session.Close()
-        session.SetMode(mgo.Monotonic, true)
-        c := session.DB(mongodb_database).C(mongodb_collection)
-        query := bson.M{"SerialNumber" : "1234998871109"}
-        change := bson.M{"$set": bson.M{ "CountGumballs" : m.CountGumballs}}
-        err = c.Update(query, change)
-        if err != nil {
-                log.Fatal(err)
-        }
-       	var result bson.M
-        err = c.Find(bson.M{"SerialNumber" : "1234998871109"}).One(&result)
-        if err != nil {
-                log.Fatal(err)
-        }        
-        fmt.Println("Gumball Machine:", result )
+		var m gumballMachine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil || m.CountGumballs < 0 {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
+		}
+		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+		result, err := setGumballCount(m.CountGumballs)
+		if err != nil {
+			mongoErrorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Gumball Machine:", result)
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
 
+// MongoDB Error Response
+func mongoErrorResponse(formatter *render.Render, w http.ResponseWriter, err error) {
+	fmt.Println("MongoDB Error: ", err)
+	if err == mgo.ErrNotFound {
+		formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Not Found"})
+		return
+	}
+	formatter.JSON(w, http.StatusServiceUnavailable, struct{ Error string }{"Database Unavailable"})
+}
+
 // API Create New Gumball Order
 func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		uuid := uuid.NewV4()
-    	var ord = order {
-					Id: uuid.String(),            		
-					OrderStatus: "Order Placed",
+		var ord = newOrder(uuid.String())
+		entry, err := insertOutbox(ord)
+		if err != nil {
+			mongoErrorResponse(formatter, w, err)
+			return
 		}
-		if orders == nil {
-			orders = make(map[string]order)
+		fmt.Println("Order: ", ord)
+		if err := sendOutbox(entry); err != nil {
+			// Accepted; the outbox relay publishes it later
+			fmt.Println("Order Not Queued Yet: ", err)
+			wakeOutbox()
+			formatter.JSON(w, http.StatusAccepted, ord)
+			return
 		}
-		orders[uuid.String()] = ord
-		queue_send(uuid.String())
-		fmt.Println( "Orders: ", orders )
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -142,156 +129,81 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		params := mux.Vars(req)
 		var uuid string = params["id"]
-		fmt.Println( "Order ID: ", uuid )
-		if uuid == ""  {
-			fmt.Println( "Orders:", orders )
-			var orders_array [] order
-			for key, value := range orders {
-    			fmt.Println("Key:", key, "Value:", value)
-    			orders_array = append(orders_array, value)
-			}
-			formatter.JSON(w, http.StatusOK, orders_array)
-		} else {
-			var ord = orders[uuid]
-			fmt.Println( "Order: ", ord )
-			formatter.JSON(w, http.StatusOK, ord)
+		fmt.Println("Order ID: ", uuid)
+		if uuid == "" {
+			ordersResponse(formatter, w)
+			return
+		}
+		ord, err := getOrder(uuid)
+		if err != nil {
+			mongoErrorResponse(formatter, w, err)
+			return
 		}
+		fmt.Println("Order: ", ord)
+		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
 
-// API Process Orders 
+// API Process Orders
+// Orders are processed by the queue consumer as they arrive;
+// this returns their current status.
 func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		// Open MongoDB Session
-		session, err := mgo.Dial(mongodb_server)
-        if err != nil {
-                panic(err)
-        }
-        defer session.Close()
-        session.SetMode(mgo.Monotonic, true)
-        c := session.DB(mongodb_database).C(mongodb_collection)
-
-       	// Get Gumball Inventory 
-        var result bson.M
-        err = c.Find(bson.M{"SerialNumber" : "1234998871109"}).One(&result)
-        if err != nil {
-                log.Fatal(err)
-        }
-
- 		var count int = result["CountGumballs"].(int)
-        fmt.Println("Current Inventory:", count )
-
-		// Process Order IDs from Queue
-		var order_ids []string = queue_receive()
-		for i := 0; i < len(order_ids); i++ {
-			var order_id = order_ids[i]
-			fmt.Println("Order ID:", order_id)
-			var ord = orders[order_id] 
-			ord.OrderStatus = "Order Processed"
-			orders[order_id] = ord
-			count -= 1
-		}
-		fmt.Println( "Orders: ", orders , "New Inventory: ", count)
-
-		// Update Gumball Inventory
-		query := bson.M{"SerialNumber" : "1234998871109"}
-        change := bson.M{"$set": bson.M{ "CountGumballs" : count}}
-        err = c.Update(query, change)
-        if err != nil {
-                log.Fatal(err)
-        }
-
-		// Return Order Status
-		formatter.JSON(w, http.StatusOK, orders)
+		ordersResponse(formatter, w)
 	}
 }
 
-// Send Order to Queue for Processing
-func queue_send(message string) {
-	conn, err := amqp.Dial("amqp://"+rabbitmq_user+":"+rabbitmq_pass+"@"+rabbitmq_server+":"+rabbitmq_port+"/")
-	failOnError(err, "Failed to connect to RabbitMQ")
-	defer conn.Close()
-
-	ch, err := conn.Channel()
-	failOnError(err, "Failed to open a channel")
-	defer ch.Close()
-
-	q, err := ch.QueueDeclare(
-		rabbitmq_queue, // name
-		false,   // durable
-		false,   // delete when unused
-		false,   // exclusive
-		false,   // no-wait
-		nil,     // arguments
-	)
-	failOnError(err, "Failed to declare a queue")
-
-	body := message
-	err = ch.Publish(
-		"",     // exchange
-		q.Name, // routing key
-		false,  // mandatory
-		false,  // immediate
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(body),
-		})
-	log.Printf(" [x] Sent %s", body)
-	failOnError(err, "Failed to publish a message")
+func ordersResponse(formatter *render.Render, w http.ResponseWriter) {
+	orders_array, err := listOrders()
+	if err != nil {
+		mongoErrorResponse(formatter, w, err)
+		return
+	}
+	formatter.JSON(w, http.StatusOK, orders_array)
 }
 
-// Receive Order from Queue to Process
-func queue_receive() []string {
-	conn, err := amqp.Dial("amqp://"+rabbitmq_user+":"+rabbitmq_pass+"@"+rabbitmq_server+":"+rabbitmq_port+"/")
-	failOnError(err, "Failed to connect to RabbitMQ")
-	defer conn.Close()
-
-	ch, err := conn.Channel()
-	failOnError(err, "Failed to open a channel")
-	defer ch.Close()
-
-	q, err := ch.QueueDeclare(
-		rabbitmq_queue, // name
-		false,   // durable
-		false,   // delete when usused
-		false,   // exclusive
-		false,   // no-wait
-		nil,     // arguments
-	)
-	failOnError(err, "Failed to declare a queue")
-
-	msgs, err := ch.Consume(
-		q.Name, // queue
-		"orders",     // consumer
-		true,   // auto-ack
-		false,  // exclusive
-		false,  // no-local
-		false,  // no-wait
-		nil,    // args
-	)
-	failOnError(err, "Failed to register a consumer")
-
-	order_ids := make(chan string)
-	go func() {
-		for d := range msgs {
-			log.Printf("Received a message: %s", d.Body)
-			order_ids <- string(d.Body)
-		}
-		close(order_ids)
-	}()
-
-	err = ch.Cancel("orders", false)
+// Process One Order from the Queue
+// An order placed on an empty machine is failed, not retried.
+// One already claimed is left to its claim or the reclaimer.
+func processOrder(order_id string) error {
+	lease, claimed, err := claimOrder(order_id)
 	if err != nil {
-	    log.Fatalf("basic.cancel: %v", err)
+		return err
 	}
+	if !claimed {
+		fmt.Println("Order Already Claimed: ", order_id)
+		return nil
+	}
+	return dispenseOrder(order_id, lease)
+}
 
-	var order_ids_array []string
-	for n := range order_ids {
-    	order_ids_array = append(order_ids_array, n)
-    }	
-
-    return order_ids_array
+// Take the Gumball and Finish an Order Claimed at lease
+func dispenseOrder(order_id string, lease time.Time) error {
+	err := takeGumball(order_id)
+	if err == errOutOfStock {
+		fmt.Println("Order Failed, Out of Stock: ", order_id)
+		_, err := finishOrder(order_id, lease, order_failed, reason_out_of_stock)
+		return err
+	}
+	if err != nil {
+		// Safe to retry: the gumball is taken once per order
+		finishOrder(order_id, lease, order_placed, "")
+		return err
+	}
+	finished, err := finishOrder(order_id, lease, order_processed, "")
+	if err != nil {
+		return err
+	}
+	if !finished {
+		// Reclaimed; the new claim records it
+		fmt.Println("Order Claim Lost: ", order_id)
+		return nil
+	}
+	if err := releaseGumball(order_id); err != nil {
+		fmt.Println("Gumball Release Error: ", order_id, err)
+	}
+	fmt.Println("Order Processed: ", order_id)
+	return nil
 }
 
 
@@ -302,15 +214,18 @@ func queue_receive() []string {
 
 	http://localhost:8080
 
-	-- RabbitMQ Create Queue:  
+	-- RabbitMQ Queues (declared on startup, see queue.go)
 
-		Queue Name: gumball
-		Durable:	no
+		Queue Name: gumball		Durable: yes
+		Queue Name: gumball.retry	Durable: yes
+		Queue Name: gumball.dead	Durable: yes
 
 	-- Gumball MongoDB Create Database
 
 		Database Name: cmpe281
 		Collection Name: gumball
+		Collection Name: orders (indexes created on startup)
+		Collection Name: outbox (indexes created on startup)
 
   	-- Gumball MongoDB Collection (Create Document) --
 
@@ -0,0 +1,95 @@
+/*
+	Gumball API in Go (Version 3)
+	Graceful Shutdown
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+	On SIGTERM or SIGINT the server stops accepting requests and
+	waits for in-flight requests.  The order consumer finishes the
+	order it is processing and cancels its subscription, so RabbitMQ
+	redelivers any prefetched orders to another replica.  The outbox
+	relay and order reclaimer stop between orders.  The RabbitMQ
+	connection is closed last.
+
+	Environment:
+		SHUTDOWN_TIMEOUT	drain deadline (default 8s, under
+					the 10s docker stop grace period)
+*/
+
+// Shutdown Signal to Workers
+var shutdown = make(chan struct{})
+var workers_done sync.WaitGroup
+
+// Shutdown Deadline from Environment
+func shutdownConfig() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Second
+}
+
+func stopping() bool {
+	select {
+	case <-shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Serve until SIGTERM or SIGINT, then drain
+func run(srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Println("Listening on ", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-sigs:
+		fmt.Println("Shutting Down: ", sig)
+	}
+
+	close(shutdown)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownConfig())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("Server Shutdown Error: ", err)
+	}
+	return drain(ctx)
+}
+
+// Wait for the workers, then close the connection
+// An order still unacked at the deadline is redelivered.
+func drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workers_done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		fmt.Println("Shutdown Deadline Passed")
+	}
+	rabbitmq.close()
+	fmt.Println("RabbitMQ Closed")
+	return nil
+}
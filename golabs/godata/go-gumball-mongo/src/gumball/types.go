@@ -6,16 +6,23 @@
 	
 package main
 
+import (
+	"time"
+)
+
 type gumballMachine struct {
-	Id             	int 	
-	CountGumballs   int    	
-	ModelNumber 	string	    
-	SerialNumber 	string	
+	Id            int    `bson:"Id"`
+	CountGumballs int    `bson:"CountGumballs"`
+	ModelNumber   string `bson:"ModelNumber"`
+	SerialNumber  string `bson:"SerialNumber"`
+	// Orders whose gumball was taken but not yet recorded
+	Dispensing []string `bson:"Dispensing,omitempty" json:"-"`
 }
 
 type order struct {
-	Id             	string 	
-	OrderStatus 	string	
+	Id           string    `bson:"Id"`
+	OrderStatus  string    `bson:"OrderStatus"`
+	Reason       string    `bson:"Reason"`
+	CreatedAt    time.Time `bson:"CreatedAt"`
+	ProcessingAt time.Time `bson:"ProcessingAt,omitempty" json:"-"`
 }
-
-var orders map[string] order
@@ -6,16 +6,28 @@
 package main
 
 import (
+	"log"
+	"net/http"
 	"os"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrateCommand(os.Args[2:]))
+	}
+
 	port := os.Getenv("PORT")
 	if len(port) == 0 {
 		port = "3000"
 	}
 
-	server := NewServer()
-	server.Run(":" + port)
+	if err := initDB(mysqlConnect()); err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{Addr: ":" + port, Handler: NewServer()}
+	if err := run(srv); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
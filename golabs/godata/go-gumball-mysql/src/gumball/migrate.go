@@ -0,0 +1,274 @@
+/*
+	Gumball API in Go (Version 4)
+	Schema Migrations
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+)
+
+/*
+	The MySQL schema is built into the binary as numbered
+	migrations.  Applied versions are recorded in the
+	schema_migrations table:
+
+		gumball migrate up [-seed]	apply pending migrations
+		gumball migrate down		revert the latest migration
+		gumball migrate status		list applied and pending
+
+	Each takes -dsn to name the database (default $MYSQL, see
+	mysql.go).  With -seed, up also inserts the default M102988
+	machine if the gumball table is empty.  Migrations are never
+	edited once released; add a new version instead.
+
+	A database whose gumball table was created by hand, before
+	migrations existed, has no schema_migrations rows.  The first
+	migrate command records version 1 as applied for it instead of
+	creating the table again.
+*/
+
+type migration struct {
+	version int
+	name    string
+	up      []string
+	down    []string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create gumball",
+		up: []string{`
+			CREATE TABLE gumball (
+			  id bigint(20) NOT NULL AUTO_INCREMENT,
+			  version bigint(20) NOT NULL,
+			  count_gumballs int(11) NOT NULL,
+			  model_number varchar(255) NOT NULL,
+			  serial_number varchar(255) NOT NULL,
+			  PRIMARY KEY (id),
+			  UNIQUE KEY serial_number (serial_number)
+			)`,
+		},
+		down: []string{
+			"DROP TABLE gumball",
+		},
+	},
+	{
+		version: 2,
+		name:    "create orders",
+		up: []string{`
+			CREATE TABLE orders (
+			  id varchar(36) NOT NULL,
+			  gumball_id bigint(20) NOT NULL,
+			  order_status varchar(255) NOT NULL,
+			  PRIMARY KEY (id),
+			  KEY order_status (order_status)
+			)`,
+		},
+		down: []string{
+			"DROP TABLE orders",
+		},
+	},
+}
+
+// Default Machine for -seed
+var seed_machine = gumballMachine{
+	Id:            1,
+	CountGumballs: 1000,
+	ModelNumber:   "M102988",
+	SerialNumber:  "1234998871109",
+}
+
+var migrate_timeout = time.Minute
+
+// Run a migrate Subcommand and Return the Exit Status
+func migrateCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	seed := flags.Bool("seed", false, "insert the default gumball machine")
+	dsn := flags.String("dsn", mysqlConnect(), "MySQL data source name")
+	usage := func() {
+		fmt.Println("Usage: gumball migrate up [-seed] | down | status [-dsn name]")
+	}
+	if len(args) < 1 {
+		usage()
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+		usage()
+		return 2
+	}
+	pool, err := openDB(*dsn)
+	if err != nil {
+		fmt.Println("Migrate Error: ", err)
+		return 1
+	}
+	db = pool
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrate_timeout)
+	defer cancel()
+	switch args[0] {
+	case "up":
+		err = migrateUp(ctx, *seed)
+	case "down":
+		err = migrateDown(ctx)
+	case "status":
+		err = migrateStatus(ctx)
+	default:
+		usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Println("Migrate Error: ", err)
+		return 1
+	}
+	return 0
+}
+
+// Apply Pending Migrations in Version Order
+func migrateUp(ctx context.Context, seed bool) error {
+	applied, err := appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		fmt.Printf("Applying Migration %d: %s\n", m.version, m.name)
+		if err := execAll(ctx, m.up); err != nil {
+			return fmt.Errorf("migration %d: %v", m.version, err)
+		}
+		_, err := db.ExecContext(ctx, "insert into schema_migrations ( version, name ) values ( ?, ? )", m.version, m.name)
+		if err != nil {
+			return err
+		}
+	}
+	if seed {
+		return seedGumball(ctx)
+	}
+	return nil
+}
+
+// Revert the Latest Applied Migration
+func migrateDown(ctx context.Context) error {
+	applied, err := appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if !applied[m.version] {
+			continue
+		}
+		fmt.Printf("Reverting Migration %d: %s\n", m.version, m.name)
+		if err := execAll(ctx, m.down); err != nil {
+			return fmt.Errorf("migration %d: %v", m.version, err)
+		}
+		_, err := db.ExecContext(ctx, "delete from schema_migrations where version = ?", m.version)
+		return err
+	}
+	fmt.Println("No Migrations Applied")
+	return nil
+}
+
+func migrateStatus(ctx context.Context) error {
+	applied, err := appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		status := "pending"
+		if applied[m.version] {
+			status = "applied"
+		}
+		fmt.Printf("%4d  %-8s  %s\n", m.version, status, m.name)
+	}
+	return nil
+}
+
+// Versions in schema_migrations, creating the table if needed
+func appliedVersions(ctx context.Context) (map[int]bool, error) {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		  version bigint(20) NOT NULL,
+		  name varchar(255) NOT NULL,
+		  applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		  PRIMARY KEY (version)
+		)`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, "select version from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return applied, baseline(ctx, applied)
+	}
+	return applied, nil
+}
+
+// Record Version 1 for a gumball Table Created by Hand
+func baseline(ctx context.Context, applied map[int]bool) error {
+	var count int
+	err := db.QueryRowContext(ctx, "select count(*) from information_schema.tables where table_schema = database() and table_name = ?", "gumball").
+		Scan(&count)
+	if err != nil || count == 0 {
+		return err
+	}
+	m := migrations[0]
+	_, err = db.ExecContext(ctx, "insert into schema_migrations ( version, name ) values ( ?, ? )", m.version, m.name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Baselined Migration %d: %s\n", m.version, m.name)
+	applied[m.version] = true
+	return nil
+}
+
+func execAll(ctx context.Context, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert the Default Machine into an Empty gumball Table
+func seedGumball(ctx context.Context) error {
+	var count int
+	if err := db.QueryRowContext(ctx, "select count(*) from gumball").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		fmt.Println("Gumball Table Not Empty, Skipping Seed")
+		return nil
+	}
+	m := seed_machine
+	_, err := db.ExecContext(ctx, "insert into gumball ( id, version, count_gumballs, model_number, serial_number ) values ( ?, 0, ?, ?, ? )",
+		m.Id, m.CountGumballs, m.ModelNumber, m.SerialNumber)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Seeded Gumball Machine: ", m)
+	return nil
+}
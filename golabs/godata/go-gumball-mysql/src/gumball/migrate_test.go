@@ -0,0 +1,222 @@
+/*
+	Gumball API in Go (Version 4)
+	Schema Migrations
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMigrateUpDown(t *testing.T) {
+	fake := useFakeSchema(t)
+	ctx := context.Background()
+
+	if err := migrateUp(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.tableNames(); got != "gumball orders schema_migrations" {
+		t.Errorf("tables after up = %q", got)
+	}
+	if len(fake.versions) != len(migrations) || fake.machines != 1 {
+		t.Errorf("up recorded %d versions and %d machines", len(fake.versions), fake.machines)
+	}
+
+	// Nothing left to apply, and the machine is not seeded twice
+	if err := migrateUp(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if fake.machines != 1 {
+		t.Errorf("seeded %d machines", fake.machines)
+	}
+
+	if err := migrateDown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fake.tables["orders"] || fake.versions[2] != "" || !fake.tables["gumball"] {
+		t.Errorf("down reverted more than the latest migration: %q %v", fake.tableNames(), fake.versions)
+	}
+	if err := migrateStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	fake := useFakeSchema(t)
+	ctx := context.Background()
+
+	// gumball table created by hand before migrations existed
+	fake.tables["gumball"] = true
+	if err := migrateUp(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if fake.versions[1] == "" || fake.versions[2] == "" || !fake.tables["orders"] {
+		t.Errorf("versions after baseline = %v, tables %q", fake.versions, fake.tableNames())
+	}
+}
+
+// Schema Stand-In
+// A database/sql driver that understands the statements in
+// migrate.go.  Creating an existing table fails, as in MySQL.
+func init() {
+	sql.Register("fakeschema", fakeSchemaDriver{})
+}
+
+type fakeSchema struct {
+	mutex    sync.Mutex
+	tables   map[string]bool
+	versions map[int]string
+	machines int
+}
+
+var fakeSchemas = struct {
+	sync.Mutex
+	next int
+	dbs  map[string]*fakeSchema
+}{dbs: make(map[string]*fakeSchema)}
+
+// useFakeSchema points db at a new empty database.
+func useFakeSchema(t *testing.T) *fakeSchema {
+	fakeSchemas.Lock()
+	fakeSchemas.next++
+	name := fmt.Sprintf("schema%d", fakeSchemas.next)
+	fake := &fakeSchema{tables: make(map[string]bool), versions: make(map[int]string)}
+	fakeSchemas.dbs[name] = fake
+	fakeSchemas.Unlock()
+
+	pool, err := sql.Open("fakeschema", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := db
+	db = pool
+	t.Cleanup(func() {
+		db.Close()
+		db = saved
+	})
+	return fake
+}
+
+func (f *fakeSchema) tableNames() string {
+	var names []string
+	for name := range f.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+type fakeSchemaDriver struct{}
+
+func (fakeSchemaDriver) Open(name string) (driver.Conn, error) {
+	fakeSchemas.Lock()
+	defer fakeSchemas.Unlock()
+	fake, ok := fakeSchemas.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("fake schema: unknown database %q", name)
+	}
+	return &fakeSchemaConn{db: fake}, nil
+}
+
+type fakeSchemaConn struct {
+	db *fakeSchema
+}
+
+func (c *fakeSchemaConn) Prepare(query string) (driver.Stmt, error) {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	return &fakeSchemaStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeSchemaConn) Close() error { return nil }
+func (c *fakeSchemaConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("fake schema: no transactions")
+}
+
+type fakeSchemaStmt struct {
+	db    *fakeSchema
+	query string
+}
+
+var createTable = regexp.MustCompile(`^create table (if not exists )?(\w+) `)
+var dropTable = regexp.MustCompile(`^drop table (\w+)$`)
+
+func (s *fakeSchemaStmt) Close() error  { return nil }
+func (s *fakeSchemaStmt) NumInput() int { return -1 }
+
+func (s *fakeSchemaStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	switch {
+	case createTable.MatchString(s.query):
+		m := createTable.FindStringSubmatch(s.query)
+		if s.db.tables[m[2]] && m[1] == "" {
+			return nil, fmt.Errorf("fake schema: table %s already exists", m[2])
+		}
+		s.db.tables[m[2]] = true
+	case dropTable.MatchString(s.query):
+		name := dropTable.FindStringSubmatch(s.query)[1]
+		if !s.db.tables[name] {
+			return nil, fmt.Errorf("fake schema: unknown table %s", name)
+		}
+		delete(s.db.tables, name)
+	case strings.HasPrefix(s.query, "insert into schema_migrations "):
+		s.db.versions[int(args[0].(int64))] = args[1].(string)
+	case strings.HasPrefix(s.query, "delete from schema_migrations "):
+		delete(s.db.versions, int(args[0].(int64)))
+	case strings.HasPrefix(s.query, "insert into gumball "):
+		s.db.machines++
+	default:
+		return nil, fmt.Errorf("fake schema: unsupported statement %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeSchemaStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	var rows [][]driver.Value
+	switch {
+	case s.query == "select version from schema_migrations":
+		for version := range s.db.versions {
+			rows = append(rows, []driver.Value{int64(version)})
+		}
+	case strings.HasPrefix(s.query, "select count(*) from information_schema.tables "):
+		var count int64
+		if s.db.tables[args[0].(string)] {
+			count = 1
+		}
+		rows = append(rows, []driver.Value{count})
+	case s.query == "select count(*) from gumball":
+		rows = append(rows, []driver.Value{int64(s.db.machines)})
+	default:
+		return nil, fmt.Errorf("fake schema: unsupported query %q", s.query)
+	}
+	return &fakeSchemaRows{rows: rows}, nil
+}
+
+type fakeSchemaRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *fakeSchemaRows) Columns() []string { return []string{"value"} }
+func (r *fakeSchemaRows) Close() error      { return nil }
+
+func (r *fakeSchemaRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
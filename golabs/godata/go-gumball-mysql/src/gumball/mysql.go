@@ -0,0 +1,133 @@
+/*
+	Gumball API in Go (Version 4)
+	MySQL Connection Pool
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/unrolled/render"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+	All handlers share one *sql.DB.  The data source name, pool
+	limits and the deadline for each request's queries are read
+	from the environment:
+
+		MYSQL			data source name (default mysql_connect)
+		MYSQL_MAX_OPEN		open connections (default 10)
+		MYSQL_MAX_IDLE		idle connections (default 5)
+		MYSQL_LIFETIME		connection lifetime (default 5m)
+		MYSQL_TIMEOUT		query deadline per request (default 3s)
+		ORDER_BATCH		orders per POST /orders (default 100)
+
+	The version column of the gumball row is bumped on every
+	update.  GET /gumball returns it as the ETag, and PUT /gumball
+	only applies when its If-Match still names that version.
+*/
+
+var db *sql.DB
+var mysql_timeout = 3 * time.Second
+
+// Open MySQL Connection Pool
+// Connections are made on first use, not here.
+func openDB(connect string) (*sql.DB, error) {
+	pool, err := sql.Open("mysql", connect)
+	if err != nil {
+		return nil, err
+	}
+	pool.SetMaxOpenConns(envInt("MYSQL_MAX_OPEN", 10))
+	pool.SetMaxIdleConns(envInt("MYSQL_MAX_IDLE", 5))
+	pool.SetConnMaxLifetime(envDuration("MYSQL_LIFETIME", 5*time.Minute))
+	mysql_timeout = envDuration("MYSQL_TIMEOUT", 3*time.Second)
+	return pool, nil
+}
+
+// Data Source Name from the Environment
+func mysqlConnect() string {
+	if v := os.Getenv("MYSQL"); len(v) > 0 {
+		return v
+	}
+	return mysql_connect
+}
+
+func envInt(key string, value int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return value
+}
+
+func envDuration(key string, value time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return value
+}
+
+// Query Deadline for a Request
+func dbContext(req *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(req.Context(), mysql_timeout)
+}
+
+func getGumball(ctx context.Context) (gumballMachine, int64, error) {
+	var m gumballMachine
+	var version int64
+	err := db.QueryRowContext(ctx, "select id, version, count_gumballs, model_number, serial_number from gumball where id = ?", 1).
+		Scan(&m.Id, &version, &m.CountGumballs, &m.ModelNumber, &m.SerialNumber)
+	return m, version, err
+}
+
+// Update Only at the Expected Version
+// Returns errVersionMismatch if the row has changed since.
+func setGumballCount(ctx context.Context, count int, version int64) error {
+	res, err := db.ExecContext(ctx, "update gumball set count_gumballs = ?, version = version + 1 where id = ? and version = ?", count, 1, version)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if _, _, err := getGumball(ctx); err != nil {
+			return err
+		}
+		return errVersionMismatch
+	}
+	return nil
+}
+
+var errVersionMismatch = errors.New("gumball: version mismatch")
+
+// ETag for a Version of the Gumball Row
+func versionTag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Version named by an If-Match header
+func matchVersion(header string) (int64, bool) {
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	return version, err == nil
+}
+
+// MySQL Error Response
+func dbErrorResponse(formatter *render.Render, w http.ResponseWriter, ctx context.Context, err error) {
+	fmt.Println("MySQL Error: ", err)
+	switch {
+	case err == sql.ErrNoRows:
+		formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Gumball Machine Not Found"})
+	case ctx.Err() == context.DeadlineExceeded, err == context.DeadlineExceeded:
+		formatter.JSON(w, http.StatusGatewayTimeout, struct{ Error string }{"Database Timeout"})
+	default:
+		formatter.JSON(w, http.StatusServiceUnavailable, struct{ Error string }{"Database Unavailable"})
+	}
+}
@@ -0,0 +1,138 @@
+/*
+	Gumball API in Go (Version 4)
+	MySQL Order Storage
+*/
+
+package main
+
+import (
+	"context"
+	"database/sql"
+)
+
+/*
+	Orders are rows of the orders table.  Each placed order is
+	processed in its own transaction, which locks the gumball row,
+	takes one gumball and records the new order status together.
+	An order placed while the machine is empty is failed and the
+	inventory is left at zero.
+*/
+
+// Order Status Values
+const (
+	order_placed    = "Order Placed"
+	order_processed = "Order Processed"
+	order_failed    = "Order Failed"
+)
+
+func insertOrder(ctx context.Context, ord order) error {
+	_, err := db.ExecContext(ctx, "insert into orders ( id, gumball_id, order_status ) values ( ?, ?, ? )", ord.Id, 1, ord.OrderStatus)
+	return err
+}
+
+func getOrder(ctx context.Context, id string) (order, error) {
+	var ord order
+	err := db.QueryRowContext(ctx, "select id, order_status from orders where id = ?", id).
+		Scan(&ord.Id, &ord.OrderStatus)
+	return ord, err
+}
+
+func listOrders(ctx context.Context) ([]order, error) {
+	return queryOrders(ctx, "select id, order_status from orders")
+}
+
+func queryOrders(ctx context.Context, query string, args ...interface{}) ([]order, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var orders_array []order
+	for rows.Next() {
+		var ord order
+		if err := rows.Scan(&ord.Id, &ord.OrderStatus); err != nil {
+			return nil, err
+		}
+		orders_array = append(orders_array, ord)
+	}
+	return orders_array, rows.Err()
+}
+
+// Placed Orders per POST /orders
+var order_batch = envInt("ORDER_BATCH", 100)
+
+// Process a Batch of Placed Orders
+// Each order gets its own mysql_timeout deadline, so a long batch
+// is not failed by one request deadline.  Returns the number of
+// orders processed and failed, and whether more may be placed.
+func processOrders(ctx context.Context) (int, int, bool, error) {
+	list_ctx, cancel := context.WithTimeout(ctx, mysql_timeout)
+	placed, err := queryOrders(list_ctx, "select id, order_status from orders where order_status = ? limit ?", order_placed, order_batch)
+	cancel()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	processed, failed := 0, 0
+	for _, ord := range placed {
+		order_ctx, cancel := context.WithTimeout(ctx, mysql_timeout)
+		status, err := processOrder(order_ctx, ord.Id)
+		if err != nil && order_ctx.Err() == context.DeadlineExceeded {
+			err = context.DeadlineExceeded
+		}
+		cancel()
+		if err != nil {
+			return processed, failed, true, err
+		}
+		switch status {
+		case order_processed:
+			processed++
+		case order_failed:
+			failed++
+		}
+	}
+	return processed, failed, len(placed) == order_batch, nil
+}
+
+// Process One Order in a Transaction
+// Returns the order's status; an order that is no longer placed
+// (processed by another request) is left unchanged.
+func processOrder(ctx context.Context, id string) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Lock the order row, then the gumball row
+	var status string
+	var gumball_id int64
+	err = tx.QueryRowContext(ctx, "select order_status, gumball_id from orders where id = ? for update", id).
+		Scan(&status, &gumball_id)
+	if err != nil {
+		return "", err
+	}
+	if status != order_placed {
+		return status, nil
+	}
+	var count int
+	err = tx.QueryRowContext(ctx, "select count_gumballs from gumball where id = ? for update", gumball_id).Scan(&count)
+	if err == sql.ErrNoRows {
+		count = 0
+	} else if err != nil {
+		return "", err
+	}
+
+	if count < 1 {
+		status = order_failed
+	} else {
+		status = order_processed
+		_, err := tx.ExecContext(ctx, "update gumball set count_gumballs = count_gumballs - 1, version = version + 1 where id = ?", gumball_id)
+		if err != nil {
+			return "", err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "update orders set order_status = ? where id = ?", status, id); err != nil {
+		return "", err
+	}
+	return status, tx.Commit()
+}
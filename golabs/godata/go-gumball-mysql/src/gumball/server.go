@@ -6,6 +6,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,8 +16,6 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
 	"github.com/satori/go.uuid"
-    "database/sql"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 /*
@@ -40,39 +40,23 @@ func NewServer() *negroni.Negroni {
 	return n
 }
 
-// Init MySQL DB Connection
-
-func init() {
-
-	db, err := sql.Open("mysql", mysql_connect)
+// Open MySQL DB Connection
+// Called from main, so the migrate subcommand can pick its own
+// database first.
+func initDB(connect string) error {
+	pool, err := openDB(connect)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	db = pool
+	ctx, cancel := context.WithTimeout(context.Background(), mysql_timeout)
+	defer cancel()
+	if m, _, err := getGumball(ctx); err != nil {
+		fmt.Println("MySQL Ping Error: ", err)
 	} else {
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		rows, err := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			err := rows.Scan(&id, &count, &model, &serial)
-			if err != nil {
-				log.Fatal(err)
-			}
-			log.Println(id, count, model, serial)
-		}
-		err = rows.Err()
-		if err != nil {
-			log.Fatal(err)
-		}
+		log.Println("MySQL Ping: ", m)
 	}
-	defer db.Close()
-
+	return nil
 }
 
 
@@ -85,6 +69,7 @@ func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/order/{id}", gumballOrderStatusHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/order", gumballOrderStatusHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/orders", gumballProcessOrdersHandler(formatter)).Methods("POST")
+	mx.HandleFunc("/v2/orders", gumballProcessOrdersV2Handler(formatter)).Methods("POST")
 }
 
 // Helper Functions
@@ -105,79 +90,58 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		result, version, err := getGumball(ctx)
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
-		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
+		w.Header().Set("ETag", versionTag(version))
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
 
 // API Update Gumball Inventory
+// Requires If-Match with the ETag from GET /gumball.
 func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-    	var m gumballMachine
-    	_ = json.NewDecoder(req.Body).Decode(&m)		
-    	fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
-	
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
-		stmt, err := db.Prepare("update gumball set count_gumballs = ? where id = ?")
-		if err != nil {
-			log.Fatal(err)
+		match := req.Header.Get("If-Match")
+		if match == "" {
+			formatter.JSON(w, http.StatusPreconditionRequired, struct{ Error string }{"If-Match Required"})
+			return
 		}
-		_, err = stmt.Exec( m.CountGumballs, 1)
-		if err != nil {
-			log.Fatal(err)
+		version, ok := matchVersion(match)
+		if !ok {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid If-Match"})
+			return
+		}
+		var m gumballMachine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
+		}
+		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		err := setGumballCount(ctx, m.CountGumballs, version)
+		if err == errVersionMismatch {
+			formatter.JSON(w, http.StatusPreconditionFailed, struct{ Error string }{"Gumball Machine Changed"})
+			return
 		}
-
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+		result, version, err := getGumball(ctx)
+		if err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
+		w.Header().Set("ETag", versionTag(version))
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
@@ -186,15 +150,17 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		uuid := uuid.NewV4()
-    	var ord = order {
-					Id: uuid.String(),            		
-					OrderStatus: "Order Placed",
+		var ord = order{
+			Id:          uuid.String(),
+			OrderStatus: order_placed,
 		}
-		if orders == nil {
-			orders = make(map[string]order)
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		if err := insertOrder(ctx, ord); err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		orders[uuid.String()] = ord
-		fmt.Println( "Orders: ", orders )
+		fmt.Println("Order: ", ord)
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -204,37 +170,67 @@ func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		params := mux.Vars(req)
 		var uuid string = params["id"]
-		fmt.Println( "Order ID: ", uuid )
-		if uuid == ""  {
-			fmt.Println( "Orders:", orders )
-			var orders_array [] order
-			for key, value := range orders {
-    			fmt.Println("Key:", key, "Value:", value)
-    			orders_array = append(orders_array, value)
+		fmt.Println("Order ID: ", uuid)
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		if uuid == "" {
+			orders_array, err := listOrders(ctx)
+			if err != nil {
+				dbErrorResponse(formatter, w, ctx, err)
+				return
 			}
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
-			var ord = orders[uuid]
-			fmt.Println( "Order: ", ord )
+			ord, err := getOrder(ctx, uuid)
+			if err == sql.ErrNoRows {
+				formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Order Not Found"})
+				return
+			}
+			if err != nil {
+				dbErrorResponse(formatter, w, ctx, err)
+				return
+			}
+			fmt.Println("Order: ", ord)
 			formatter.JSON(w, http.StatusOK, ord)
 		}
 	}
 }
 
-// API Process Orders 
+// API Process Orders
+// Orders placed while the machine is empty are failed.  At most
+// order_batch orders are processed per request.
 func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		for key, value := range orders {
-    			fmt.Println("Key:", key, "Value:", value)
-    			var ord = orders[key] 
-    			ord.OrderStatus = "Order Processed"
-    			orders[key] = ord
-			}
-		fmt.Println( "Orders: ", orders )
+		if _, _, _, err := processOrderBatch(req); err != nil {
+			dbErrorResponse(formatter, w, req.Context(), err)
+			return
+		}
 		formatter.JSON(w, http.StatusOK, "Orders Processed!")
 	}
 }
 
+// API Process Orders (Version 2)
+// As /orders, but returns the counts and whether orders remain.
+func gumballProcessOrdersV2Handler(formatter *render.Render) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		processed, failed, more, err := processOrderBatch(req)
+		if err != nil {
+			dbErrorResponse(formatter, w, req.Context(), err)
+			return
+		}
+		formatter.JSON(w, http.StatusOK, struct {
+			Processed, Failed int
+			More              bool
+		}{processed, failed, more})
+	}
+}
+
+func processOrderBatch(req *http.Request) (int, int, bool, error) {
+	processed, failed, more, err := processOrders(req.Context())
+	fmt.Println("Orders Processed: ", processed, " Failed: ", failed, " More: ", more)
+	return processed, failed, more, err
+}
+
 
 
 /*
@@ -243,30 +239,15 @@ func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 
 		Database Schema: cmpe281
 
-	-- Create Database Table 
+	-- Create Tables and Load Data (see migrate.go)
 
-		CREATE TABLE gumball (
-		  id bigint(20) NOT NULL AUTO_INCREMENT,
-		  version bigint(20) NOT NULL,
-		  count_gumballs int(11) NOT NULL,
-		  model_number varchar(255) NOT NULL,
-		  serial_number varchar(255) NOT NULL,
-		  PRIMARY KEY (id),
-		  UNIQUE KEY serial_number (serial_number)
-		) ;
-
-	-- Load Data
-
-		insert into gumball ( id, version, count_gumballs, model_number, serial_number ) 
-		values ( 1, 0, 1000, 'M102988', '1234998871109' ) ;
+		gumball migrate up -seed
 
 	-- Verify Data 
 
+		gumball migrate status
 		select * from gumball ;
+		select * from orders ;
 
 
 */
-
-  
-
-
@@ -0,0 +1,238 @@
+/*
+	Gumball API in Go
+	Redis Machine Cache
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/satori/go.uuid"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	GET /gumball reads the machine through a cache-aside copy in
+	Redis that expires after MACHINE_CACHE_TTL (default 30s).
+	PUT /gumball and order processing invalidate it once MySQL has
+	changed.
+
+	Redis Keys:
+		gumball:machine:1	JSON machine record
+		gumball:machine:1:gen	generation, bumped by each invalidation
+		gumball:machine:1:fill	lock held by the replica filling the cache
+
+	A fill is only written if the generation is unchanged since the
+	MySQL read, so a slow reader cannot put back a record that was
+	invalidated while it was reading.
+
+	On a miss only one fill per replica reads MySQL; requests that
+	miss while it runs share its result.  The fill runs under its
+	own mysql_timeout, so a request whose deadline passes or whose
+	client goes away stops waiting without failing the others.
+	Across replicas the fill lock lets one replica read MySQL while
+	the others poll the cache for up to machine_fill_wait before
+	reading MySQL themselves.  Redis errors fall back to MySQL.
+*/
+
+var machine_cache_key = "gumball:machine:1"
+var machine_cache_gen = "gumball:machine:1:gen"
+var machine_cache_lock = "gumball:machine:1:fill"
+
+var machine_cache_ttl = envDuration("MACHINE_CACHE_TTL", 30*time.Second)
+
+// Fill Lock Expiry and Polling
+var machine_fill_wait = time.Second
+var machine_fill_poll = 50 * time.Millisecond
+
+// A Fill in Progress on this Replica
+type machineFill struct {
+	done   chan struct{}
+	m      gumballMachine
+	cached bool
+	err    error
+}
+
+// One Fill at a Time per Replica
+var machine_fill *machineFill
+var machine_fill_mutex sync.Mutex
+
+// Cache Counters since Start
+type cacheStats struct {
+	Hits          int64
+	Misses        int64
+	Errors        int64
+	Invalidations int64
+}
+
+var machine_cache_stats cacheStats
+
+// Write the Record if the Generation is Unchanged
+// KEYS[1] record, KEYS[2] generation; ARGV generation, record, ttl ms
+var redis_fill_machine = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '0') ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
+// Release the Fill Lock if still Held
+var redis_release_lock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Machine from the Cache, or MySQL on a Miss
+// Reports whether the record came from the cache.  Returns
+// ctx.Err() if ctx ends while waiting for the fill.
+func cachedGumball(ctx context.Context) (gumballMachine, bool, error) {
+	if m, ok := readMachineCache(); ok {
+		atomic.AddInt64(&machine_cache_stats.Hits, 1)
+		return m, true, nil
+	}
+	f := startMachineFill()
+	select {
+	case <-f.done:
+		return f.m, f.cached, f.err
+	case <-ctx.Done():
+		return gumballMachine{}, false, ctx.Err()
+	}
+}
+
+// Join the Replica's Fill, or Start One
+func startMachineFill() *machineFill {
+	machine_fill_mutex.Lock()
+	defer machine_fill_mutex.Unlock()
+	if machine_fill != nil {
+		return machine_fill
+	}
+	f := &machineFill{done: make(chan struct{})}
+	machine_fill = f
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mysql_timeout)
+		defer cancel()
+		f.m, f.cached, f.err = fillMachine(ctx)
+		machine_fill_mutex.Lock()
+		machine_fill = nil
+		machine_fill_mutex.Unlock()
+		close(f.done)
+	}()
+	return f
+}
+
+func fillMachine(ctx context.Context) (gumballMachine, bool, error) {
+	if m, ok := readMachineCache(); ok {
+		atomic.AddInt64(&machine_cache_stats.Hits, 1)
+		return m, true, nil
+	}
+	atomic.AddInt64(&machine_cache_stats.Misses, 1)
+
+	token := uuid.NewV4().String()
+	locked, err := redis_client.SetNX(machine_cache_lock, token, machine_fill_wait).Result()
+	if err != nil {
+		cacheError(err)
+	} else if !locked {
+		if m, ok := waitMachineCache(ctx); ok {
+			return m, true, nil
+		}
+	} else {
+		defer redis_release_lock.Run(redis_client, []string{machine_cache_lock}, token)
+	}
+
+	gen, err := redis_client.Get(machine_cache_gen).Result()
+	if err == redis.Nil {
+		gen, err = "0", nil
+	}
+	m, dbErr := getGumball(ctx)
+	if dbErr != nil {
+		return m, false, dbErr
+	}
+	if err != nil {
+		cacheError(err)
+	} else {
+		fillMachineCache(gen, m)
+	}
+	return m, false, nil
+}
+
+func readMachineCache() (gumballMachine, bool) {
+	var m gumballMachine
+	val, err := redis_client.Get(machine_cache_key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			cacheError(err)
+		}
+		return m, false
+	}
+	if err := json.Unmarshal(val, &m); err != nil {
+		cacheError(err)
+		return m, false
+	}
+	return m, true
+}
+
+// Poll the Cache while another Replica Fills it
+func waitMachineCache(ctx context.Context) (gumballMachine, bool) {
+	deadline := time.Now().Add(machine_fill_wait)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return gumballMachine{}, false
+		case <-time.After(machine_fill_poll):
+		}
+		if m, ok := readMachineCache(); ok {
+			atomic.AddInt64(&machine_cache_stats.Hits, 1)
+			return m, true
+		}
+	}
+	return gumballMachine{}, false
+}
+
+func fillMachineCache(gen string, m gumballMachine) {
+	val, err := json.Marshal(m)
+	if err != nil {
+		cacheError(err)
+		return
+	}
+	keys := []string{machine_cache_key, machine_cache_gen}
+	err = redis_fill_machine.Run(redis_client, keys, gen, val, millisArg(machine_cache_ttl)).Err()
+	if err != nil {
+		cacheError(err)
+	}
+}
+
+// Drop the Cached Machine after a MySQL Update
+// If Redis is down the record expires after machine_cache_ttl.
+func invalidateMachine() {
+	atomic.AddInt64(&machine_cache_stats.Invalidations, 1)
+	_, err := redis_client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Incr(machine_cache_gen)
+		pipe.Del(machine_cache_key)
+		return nil
+	})
+	if err != nil {
+		cacheError(err)
+	}
+}
+
+func cacheError(err error) {
+	atomic.AddInt64(&machine_cache_stats.Errors, 1)
+	fmt.Println("Redis Cache Error: ", err)
+}
+
+func machineCacheStats() cacheStats {
+	return cacheStats{
+		Hits:          atomic.LoadInt64(&machine_cache_stats.Hits),
+		Misses:        atomic.LoadInt64(&machine_cache_stats.Misses),
+		Errors:        atomic.LoadInt64(&machine_cache_stats.Errors),
+		Invalidations: atomic.LoadInt64(&machine_cache_stats.Invalidations),
+	}
+}
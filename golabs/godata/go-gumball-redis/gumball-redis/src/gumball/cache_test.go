@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFillMachineCacheOnlyAtGeneration(t *testing.T) {
+	useRedis(t)
+	m := gumballMachine{Id: 1, CountGumballs: 5, ModelNumber: "M102988", SerialNumber: gumball_serial}
+
+	fillMachineCache("0", m)
+	if cached, ok := readMachineCache(); !ok || cached != m {
+		t.Fatalf("cache after fill = %+v, %v", cached, ok)
+	}
+
+	// A reader that started before the invalidation must not put
+	// back its record
+	invalidateMachine()
+	fillMachineCache("0", m)
+	if _, ok := readMachineCache(); ok {
+		t.Error("stale fill written after invalidation")
+	}
+	fillMachineCache("1", m)
+	if _, ok := readMachineCache(); !ok {
+		t.Error("fill at the current generation not written")
+	}
+}
+
+func TestReleaseFillLock(t *testing.T) {
+	useRedis(t)
+	redis_client.Set(machine_cache_lock, "mine", time.Minute)
+
+	redis_release_lock.Run(redis_client, []string{machine_cache_lock}, "theirs")
+	if redis_client.Get(machine_cache_lock).Val() != "mine" {
+		t.Fatal("lock released with another token")
+	}
+	redis_release_lock.Run(redis_client, []string{machine_cache_lock}, "mine")
+	if n := redis_client.Exists(machine_cache_lock).Val(); n != 0 {
+		t.Error("lock still held after release")
+	}
+}
+
+func TestCachedGumballSharesOneFill(t *testing.T) {
+	useRedis(t)
+	fake := useFakeMachine(t, 7)
+	fake.block = make(chan struct{})
+
+	type result struct {
+		m   gumballMachine
+		err error
+	}
+	results := make(chan result, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			m, _, err := cachedGumball(context.Background())
+			results <- result{m, err}
+		}()
+	}
+
+	// A request whose deadline passes stops waiting on its own
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := cachedGumball(ctx); err != context.DeadlineExceeded {
+		t.Errorf("cancelled request: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("cancelled request waited %v", time.Since(start))
+	}
+
+	close(fake.block)
+	for i := 0; i < 3; i++ {
+		r := <-results
+		if r.err != nil || r.m.CountGumballs != 7 {
+			t.Errorf("shared fill = %+v, %v", r.m, r.err)
+		}
+	}
+	if fake.reads != 1 {
+		t.Errorf("MySQL read %d times for one miss", fake.reads)
+	}
+}
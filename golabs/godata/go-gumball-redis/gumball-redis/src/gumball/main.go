@@ -6,6 +6,8 @@
 package main
 
 import (
+	"log"
+	"net/http"
 	"os"
 )
 
@@ -16,6 +18,13 @@ func main() {
 		port = "3000"
 	}
 
-	server := NewServer()
-	server.Run(":" + port)
+	initConnections()
+
+	// Orders are processed from the Redis stream
+	order_processors()
+
+	srv := &http.Server{Addr: ":" + port, Handler: NewServer()}
+	if err := run(srv); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTakeGumballOncePerOrder(t *testing.T) {
+	fake := useFakeMachine(t, 1)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := takeGumball(ctx, "o1"); err != nil {
+			t.Fatalf("take %d: %v", i, err)
+		}
+	}
+	if fake.count != 0 || len(fake.orders) != 1 {
+		t.Errorf("after taking twice for o1: count %d, orders %v", fake.count, fake.orders)
+	}
+
+	// Out of stock: the order is not recorded
+	if err := takeGumball(ctx, "o2"); err != errOutOfStock {
+		t.Fatalf("empty machine: %v", err)
+	}
+	if fake.orders["o2"] {
+		t.Error("out of stock order recorded")
+	}
+}
+
+// MySQL Stand-In
+// A database/sql driver that understands the gumball and
+// gumball_orders statements in mysql.go.  Changes in a
+// transaction are applied on Commit.  Reads of the machine are
+// counted, and wait for block when it is set.
+func init() {
+	sql.Register("fakemachine", fakeMachineDriver{})
+}
+
+type fakeMachine struct {
+	mutex  sync.Mutex
+	count  int
+	orders map[string]bool
+	reads  int
+	block  chan struct{}
+}
+
+var fake_machines = struct {
+	sync.Mutex
+	next int
+	dbs  map[string]*fakeMachine
+}{dbs: make(map[string]*fakeMachine)}
+
+// useFakeMachine points db at a machine holding count gumballs.
+func useFakeMachine(t *testing.T, count int) *fakeMachine {
+	fake_machines.Lock()
+	fake_machines.next++
+	name := fmt.Sprintf("machine%d", fake_machines.next)
+	fake := &fakeMachine{count: count, orders: make(map[string]bool)}
+	fake_machines.dbs[name] = fake
+	fake_machines.Unlock()
+
+	pool, err := sql.Open("fakemachine", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := db
+	db = pool
+	t.Cleanup(func() {
+		db.Close()
+		db = saved
+	})
+	return fake
+}
+
+type fakeMachineDriver struct{}
+
+func (fakeMachineDriver) Open(name string) (driver.Conn, error) {
+	fake_machines.Lock()
+	defer fake_machines.Unlock()
+	fake, ok := fake_machines.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("fake machine: unknown database %q", name)
+	}
+	return &fakeMachineConn{db: fake}, nil
+}
+
+type fakeMachineConn struct {
+	db *fakeMachine
+	tx *fakeMachineTx
+}
+
+func (c *fakeMachineConn) Prepare(query string) (driver.Stmt, error) {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	return &fakeMachineStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeMachineConn) Close() error { return nil }
+
+// The machine is locked for the whole transaction.
+func (c *fakeMachineConn) Begin() (driver.Tx, error) {
+	c.db.mutex.Lock()
+	c.tx = &fakeMachineTx{conn: c, count: c.db.count, orders: make(map[string]bool)}
+	return c.tx, nil
+}
+
+type fakeMachineTx struct {
+	conn   *fakeMachineConn
+	count  int
+	orders map[string]bool
+}
+
+func (tx *fakeMachineTx) Commit() error {
+	db := tx.conn.db
+	db.count = tx.count
+	for id := range tx.orders {
+		db.orders[id] = true
+	}
+	return tx.Rollback()
+}
+
+func (tx *fakeMachineTx) Rollback() error {
+	tx.conn.tx = nil
+	tx.conn.db.mutex.Unlock()
+	return nil
+}
+
+type fakeMachineStmt struct {
+	conn  *fakeMachineConn
+	query string
+}
+
+func (s *fakeMachineStmt) Close() error  { return nil }
+func (s *fakeMachineStmt) NumInput() int { return -1 }
+
+func (s *fakeMachineStmt) Exec(args []driver.Value) (driver.Result, error) {
+	tx := s.conn.tx
+	if tx == nil {
+		return nil, fmt.Errorf("fake machine: %q outside a transaction", s.query)
+	}
+	switch {
+	case strings.HasPrefix(s.query, "insert ignore into gumball_orders "):
+		id := args[0].(string)
+		if s.conn.db.orders[id] || tx.orders[id] {
+			return driver.RowsAffected(0), nil
+		}
+		tx.orders[id] = true
+	case strings.HasPrefix(s.query, "update gumball set count_gumballs = count_gumballs - 1 "):
+		if tx.count < 1 {
+			return driver.RowsAffected(0), nil
+		}
+		tx.count--
+	default:
+		return nil, fmt.Errorf("fake machine: unsupported statement %q", s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeMachineStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "select id, count_gumballs, model_number, serial_number from gumball ") {
+		return nil, fmt.Errorf("fake machine: unsupported query %q", s.query)
+	}
+	db := s.conn.db
+	if db.block != nil {
+		<-db.block
+	}
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.reads++
+	return &fakeMachineRows{row: []driver.Value{int64(1), int64(db.count), "M102988", gumball_serial}}, nil
+}
+
+type fakeMachineRows struct {
+	row []driver.Value
+}
+
+func (r *fakeMachineRows) Columns() []string {
+	return []string{"id", "count_gumballs", "model_number", "serial_number"}
+}
+
+func (r *fakeMachineRows) Close() error { return nil }
+
+func (r *fakeMachineRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
@@ -0,0 +1,243 @@
+/*
+	Gumball API in Go
+	Redis Order Storage
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/unrolled/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Redis Keys:
+		gumball:order:{id}	hash of order fields
+		gumball:orders		sorted set of order ids by creation time
+		gumball:orders:expiry	sorted set of order ids by expiry time
+
+	New orders are also appended to the gumball:orders:stream work
+	queue (see stream.go) in the same transaction.
+
+	Times are stored as Unix milliseconds.  Finished orders expire
+	after ORDER_TTL if it is set (default: kept).  Finishing an
+	order also drops up to order_sweep_batch expired ids from the
+	index, and a page of orders drops any it reads.
+
+	Listing pages through the index in creation order, ties broken
+	by id as Redis orders equal scores.  The cursor returned with a
+	page is the creation time and id of its last order, and the
+	next page starts after that pair, so pages stay in step while
+	orders are added or expire.
+*/
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+var redis_orders = "gumball:orders"
+var redis_orders_expiry = "gumball:orders:expiry"
+var gumball_serial = "1234998871109"
+
+var order_ttl = envDuration("ORDER_TTL", 0)
+
+// Orders per Page
+var order_page_size = 20
+var order_page_max = 100
+
+// Expired Ids Dropped per Finished Order
+var order_sweep_batch = 100
+
+// Order Status Values
+const (
+	order_placed    = "Order Placed"
+	order_processed = "Order Processed"
+	order_failed    = "Order Failed"
+)
+
+func orderKey(id string) string {
+	return "gumball:order:" + id
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+// Set the Final Status and Expiry of a Placed Order
+// KEYS[1] order hash, KEYS[2] index, KEYS[3] expiry index; ARGV
+// status, processed time, ttl in ms (0 to keep), order id, sweep
+// batch.  Returns 0 for an unknown order, 2 if it already finished.
+var redis_finish_order = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], 'OrderStatus')
+if not status then
+	return 0
+end
+if status ~= 'Order Placed' then
+	return 2
+end
+redis.call('HSET', KEYS[1], 'OrderStatus', ARGV[1])
+redis.call('HSET', KEYS[1], 'ProcessedAt', ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+	redis.call('ZADD', KEYS[3], tonumber(ARGV[2]) + tonumber(ARGV[3]), ARGV[4])
+end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[2], 'LIMIT', 0, ARGV[5])
+if #expired > 0 then
+	redis.call('ZREM', KEYS[2], unpack(expired))
+	redis.call('ZREM', KEYS[3], unpack(expired))
+end
+return 1
+`)
+
+func createOrder(ord order) error {
+	_, err := redis_client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HMSet(orderKey(ord.Id), map[string]interface{}{
+			"Id":           ord.Id,
+			"OrderStatus":  ord.OrderStatus,
+			"SerialNumber": ord.SerialNumber,
+			"CreatedAt":    millis(ord.CreatedAt),
+		})
+		pipe.ZAdd(redis_orders, redis.Z{Score: float64(millis(ord.CreatedAt)), Member: ord.Id})
+		pipe.Process(streamAdd(ord.Id))
+		return nil
+	})
+	return err
+}
+
+// Returns redis.Nil for an unknown order.
+func getOrder(id string) (order, error) {
+	fields, err := redis_client.HGetAll(orderKey(id)).Result()
+	if err != nil {
+		return order{}, err
+	}
+	if len(fields) == 0 {
+		return order{}, redis.Nil
+	}
+	return parseOrder(fields), nil
+}
+
+func parseOrder(fields map[string]string) order {
+	ord := order{
+		Id:           fields["Id"],
+		OrderStatus:  fields["OrderStatus"],
+		SerialNumber: fields["SerialNumber"],
+	}
+	if ms, err := strconv.ParseInt(fields["CreatedAt"], 10, 64); err == nil {
+		ord.CreatedAt = fromMillis(ms)
+	}
+	if ms, err := strconv.ParseInt(fields["ProcessedAt"], 10, 64); err == nil {
+		t := fromMillis(ms)
+		ord.ProcessedAt = &t
+	}
+	return ord
+}
+
+// Finish a Placed Order with status
+// An order that already finished is left as it is.  Returns
+// redis.Nil for an unknown order.
+func finishOrder(id string, status string) error {
+	ttl := int64(order_ttl / time.Millisecond)
+	keys := []string{orderKey(id), redis_orders, redis_orders_expiry}
+	n, err := redis_finish_order.Run(redis_client, keys, status, millis(time.Now()), ttl, id, order_sweep_batch).Result()
+	if err != nil {
+		return err
+	}
+	if n == int64(0) {
+		return redis.Nil
+	}
+	return nil
+}
+
+// One Page of Orders after cursor
+// Returns the cursor of the next page, or "" after the last page.
+func listOrders(cursor string, count int) ([]order, string, error) {
+	entries, err := pageEntries(cursor, count)
+	if err != nil {
+		return nil, "", err
+	}
+	cmds := make([]*redis.StringStringMapCmd, len(entries))
+	_, err = redis_client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, z := range entries {
+			cmds[i] = pipe.HGetAll(orderKey(z.Member.(string)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	orders_array := []order{}
+	var expired []interface{}
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			expired = append(expired, entries[i].Member)
+			continue
+		}
+		orders_array = append(orders_array, parseOrder(cmd.Val()))
+	}
+	if len(expired) > 0 {
+		redis_client.ZRem(redis_orders, expired...)
+	}
+
+	next := ""
+	if len(entries) == count {
+		last := entries[len(entries)-1]
+		next = fmt.Sprintf("%d:%s", int64(last.Score), last.Member)
+	}
+	return orders_array, next, nil
+}
+
+// Index Entries after cursor
+// The rest of the cursor's millisecond comes first, then later
+// times, so the cursor's order need not still be in the index.
+func pageEntries(cursor string, count int) ([]redis.Z, error) {
+	if cursor == "" {
+		return redis_client.ZRangeWithScores(redis_orders, 0, int64(count)-1).Result()
+	}
+	parts := strings.SplitN(cursor, ":", 2)
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if len(parts) != 2 || err != nil {
+		return nil, errInvalidCursor
+	}
+	score := strconv.FormatInt(ms, 10)
+	same, err := redis_client.ZRangeByScoreWithScores(redis_orders, redis.ZRangeBy{Min: score, Max: score}).Result()
+	if err != nil {
+		return nil, err
+	}
+	var entries []redis.Z
+	for _, z := range same {
+		if z.Member.(string) > parts[1] && len(entries) < count {
+			entries = append(entries, z)
+		}
+	}
+	if len(entries) == count {
+		return entries, nil
+	}
+	later, err := redis_client.ZRangeByScoreWithScores(redis_orders, redis.ZRangeBy{
+		Min:   "(" + score,
+		Max:   "+inf",
+		Count: int64(count - len(entries)),
+	}).Result()
+	return append(entries, later...), err
+}
+
+// Redis Error Response
+func redisErrorResponse(formatter *render.Render, w http.ResponseWriter, err error) {
+	fmt.Println("Redis Error: ", err)
+	switch err {
+	case redis.Nil:
+		formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Order Not Found"})
+	case errInvalidCursor:
+		formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Cursor"})
+	default:
+		formatter.JSON(w, http.StatusServiceUnavailable, struct{ Error string }{"Redis Unavailable"})
+	}
+}
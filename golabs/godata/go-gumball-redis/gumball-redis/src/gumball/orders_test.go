@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Redis Stand-In
+// miniredis speaks the Redis protocol in-process and runs Lua.
+func useRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	saved := redis_client
+	redis_client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		redis_client.Close()
+		redis_client = saved
+	})
+	return mr
+}
+
+func placeOrders(t *testing.T, created time.Time, ids ...string) {
+	for _, id := range ids {
+		ord := order{Id: id, OrderStatus: order_placed, SerialNumber: gumball_serial, CreatedAt: created}
+		if err := createOrder(ord); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Pages of count orders, following cursors to the end
+func pageIds(t *testing.T, cursor string, count int) string {
+	var pages []string
+	for {
+		orders_array, next, err := listOrders(cursor, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, ord := range orders_array {
+			ids = append(ids, ord.Id)
+		}
+		pages = append(pages, strings.Join(ids, ","))
+		if next == "" {
+			return strings.Join(pages, " ")
+		}
+		cursor = next
+	}
+}
+
+func TestListOrdersPages(t *testing.T) {
+	useRedis(t)
+	now := time.Now()
+	placeOrders(t, now, "a", "b", "c", "d", "e")
+	placeOrders(t, now.Add(time.Millisecond), "f")
+
+	if got := pageIds(t, "", 2); got != "a,b c,d e,f " {
+		t.Errorf("pages = %q", got)
+	}
+	if got := pageIds(t, "", 6); got != "a,b,c,d,e,f " {
+		t.Errorf("pages = %q", got)
+	}
+	if _, _, err := listOrders("b", 2); err != errInvalidCursor {
+		t.Errorf("bad cursor: %v", err)
+	}
+}
+
+func TestListOrdersAfterExpiredCursor(t *testing.T) {
+	useRedis(t)
+	now := time.Now()
+	placeOrders(t, now, "a", "b", "c", "d")
+
+	_, next, err := listOrders("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b expires and leaves the index before the next page
+	redis_client.Del(orderKey("b"))
+	redis_client.ZRem(redis_orders, "b")
+	if got := pageIds(t, next, 2); got != "c,d " {
+		t.Errorf("pages after expired cursor = %q", got)
+	}
+}
+
+func TestFinishOrder(t *testing.T) {
+	useRedis(t)
+	placeOrders(t, time.Now(), "a")
+
+	if err := finishOrder("a", order_processed); err != nil {
+		t.Fatal(err)
+	}
+	// Already finished: left as it is
+	if err := finishOrder("a", order_failed); err != nil {
+		t.Fatal(err)
+	}
+	ord, err := getOrder("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.OrderStatus != order_processed || ord.ProcessedAt == nil {
+		t.Errorf("finished order = %+v", ord)
+	}
+	if err := finishOrder("missing", order_processed); err != redis.Nil {
+		t.Errorf("unknown order: %v", err)
+	}
+}
+
+func TestFinishOrderSweepsExpiredIds(t *testing.T) {
+	mr := useRedis(t)
+	defer func(ttl time.Duration) { order_ttl = ttl }(order_ttl)
+	order_ttl = time.Millisecond
+	placeOrders(t, time.Now(), "a", "b")
+
+	if err := finishOrder("a", order_processed); err != nil {
+		t.Fatal(err)
+	}
+	// miniredis expires keys only when its clock moves
+	time.Sleep(5 * time.Millisecond)
+	mr.FastForward(5 * time.Millisecond)
+	if err := finishOrder("b", order_processed); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := redis_client.ZRange(redis_orders, 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("index after sweep = %v", ids)
+	}
+	if ids := redis_client.ZRange(redis_orders_expiry, 0, -1).Val(); len(ids) != 1 || ids[0] != "b" {
+		t.Errorf("expiry index after sweep = %v", ids)
+	}
+}
@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,9 +15,9 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
 	"github.com/satori/go.uuid"
-    "database/sql"
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/go-redis/redis"
+	"strconv"
+	"time"
 )
 
 /*
@@ -52,10 +53,11 @@ func NewServer() *negroni.Negroni {
 
 var redis_client *redis.Client
 
-func init() {
+// Called from main, so tests can point redis_client elsewhere.
+func initConnections() {
 
 	// Test Redis Connection
-	redis_client := redis.NewClient(&redis.Options{
+	redis_client = redis.NewClient(&redis.Options{
 		Addr:     redis_connect,
 		Password: "", // no password set
 		DB:       0,  // use default DB
@@ -65,34 +67,21 @@ func init() {
 	fmt.Println(pong, err)
 
 	// Test MySQL Connection
-	db, err := sql.Open("mysql", mysql_connect)
+	pool, err := openDB(mysql_connect)
 	if err != nil {
 		log.Fatal(err)
+	}
+	db = pool
+	ctx, cancel := context.WithTimeout(context.Background(), mysql_timeout)
+	defer cancel()
+	if m, err := getGumball(ctx); err != nil {
+		fmt.Println("MySQL Ping Error: ", err)
 	} else {
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		rows, err := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			err := rows.Scan(&id, &count, &model, &serial)
-			if err != nil {
-				log.Fatal(err)
-			}
-			log.Println(id, count, model, serial)
-		}
-		err = rows.Err()
-		if err != nil {
-			log.Fatal(err)
-		}
+		log.Println("MySQL Ping: ", m)
+	}
+	if err := ensureSchema(ctx); err != nil {
+		fmt.Println("MySQL Schema Error: ", err)
 	}
-	defer db.Close()
 
 }
 
@@ -102,10 +91,10 @@ func initRoutes(mx *mux.Router, formatter *render.Render) {
 	mx.HandleFunc("/ping", pingHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/gumball", gumballHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/gumball", gumballUpdateHandler(formatter)).Methods("PUT")
+	mx.HandleFunc("/gumball/cache", gumballCacheHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/order", gumballNewOrderHandler(formatter)).Methods("POST")
 	mx.HandleFunc("/order", gumballOrderStatusHandler(formatter)).Methods("GET")
 	mx.HandleFunc("/order/{id}", gumballOrderStatusHandler(formatter)).Methods("GET")
-	mx.HandleFunc("/order/{id}", gumballProcessOrdersHandler(formatter)).Methods("POST")
 }
 
 // Helper Functions
@@ -126,79 +115,55 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		result, hit, err := cachedGumball(ctx)
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+		if hit {
+			w.Header().Set("X-Cache", "HIT")
+		} else {
+			w.Header().Set("X-Cache", "MISS")
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
 
+// API Gumball Cache Counters
+func gumballCacheHandler(formatter *render.Render) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		formatter.JSON(w, http.StatusOK, machineCacheStats())
+	}
+}
+
 // API Update Gumball Inventory
 func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-    	var m gumballMachine
-    	_ = json.NewDecoder(req.Body).Decode(&m)		
-    	fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
-	
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
-		stmt, err := db.Prepare("update gumball set count_gumballs = ? where id = ?")
-		if err != nil {
-			log.Fatal(err)
-		}
-		_, err = stmt.Exec( m.CountGumballs, 1)
-		if err != nil {
-			log.Fatal(err)
+		var m gumballMachine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
 		}
+		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
 
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		// A timed out update may still have been applied
+		err := setGumballCount(ctx, m.CountGumballs)
+		invalidateMachine()
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+		result, err := getGumball(ctx)
+		if err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
@@ -206,23 +171,17 @@ func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 // API Create New Gumball Order
 func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		redis_client := redis.NewClient(&redis.Options{
-			Addr:     redis_connect,
-			Password: "", // no password set
-			DB:       0,  // use default DB
-		})
-
-		uuid := uuid.NewV4()
-    	var ord = order {
-					Id: uuid.String(),            		
-					OrderStatus: "Order Placed",
+		var ord = order{
+			Id:           uuid.NewV4().String(),
+			OrderStatus:  order_placed,
+			SerialNumber: gumball_serial,
+			CreatedAt:    time.Now().UTC(),
 		}
-		err := redis_client.Set(uuid.String(), "Order Placed", 0).Err()
-		if err != nil {
-			panic(err)
+		if err := createOrder(ord); err != nil {
+			redisErrorResponse(formatter, w, err)
+			return
 		}
-		fmt.Println( "Order: ", ord )
+		fmt.Println("Order: ", ord)
 		formatter.JSON(w, http.StatusOK, ord)
 	}
 }
@@ -230,75 +189,42 @@ func gumballNewOrderHandler(formatter *render.Render) http.HandlerFunc {
 // API Get Order Status
 func gumballOrderStatusHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		redis_client := redis.NewClient(&redis.Options{
-			Addr:     redis_connect,
-			Password: "", // no password set
-			DB:       0,  // use default DB
-		})
-
 		params := mux.Vars(req)
 		var uuid string = params["id"]
-		fmt.Println( "Order ID: ", uuid )
-
-		if uuid == ""  {
-			var keys = redis_client.Keys("*").Val()
-			fmt.Println( "Orders:", keys )
-			var orders_array [] order
-			for _, key := range keys {
-				val, _ := redis_client.Get(key).Result()
-    			fmt.Println("Key:", key, "Value:", val)
-		   		var ord = order {
-					Id: key,            		
-					OrderStatus: val,
+		fmt.Println("Order ID: ", uuid)
+
+		if uuid == "" {
+			count := order_page_size
+			if v := req.URL.Query().Get("count"); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 1 || n > order_page_max {
+					formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Count"})
+					return
 				}
-    			orders_array = append(orders_array, ord)
+				count = n
+			}
+			orders_array, next, err := listOrders(req.URL.Query().Get("cursor"), count)
+			if err != nil {
+				redisErrorResponse(formatter, w, err)
+				return
+			}
+			fmt.Println("Orders:", len(orders_array), "Next:", next)
+			if next != "" {
+				w.Header().Set("X-Next-Cursor", next)
 			}
 			formatter.JSON(w, http.StatusOK, orders_array)
 		} else {
-			val, _ := redis_client.Get(uuid).Result()
-		   	var ord = order {
-							Id: uuid,            		
-							OrderStatus: val,
-				}
-			fmt.Println( "Order: ", ord )
-			formatter.JSON(w, http.StatusOK, ord)
-		}
-	}
-}
-
-// API Process Orders 
-func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-
-		redis_client := redis.NewClient(&redis.Options{
-			Addr:     redis_connect,
-			Password: "", // no password set
-			DB:       0,  // use default DB
-		})
-
-		params := mux.Vars(req)
-		var uuid string = params["id"]
-		fmt.Println( "Order ID: ", uuid )
-
-		if uuid == ""  {
-			formatter.JSON(w, http.StatusOK, "Order ID Missing!")
-		} else {
-			err := redis_client.Set(uuid, "Order Processed", 0).Err()
+			ord, err := getOrder(uuid)
 			if err != nil {
-				formatter.JSON(w, http.StatusOK, "Order ID Error!")
-			} else {
-		   		var ord = order {
-					Id: uuid,            		
-					OrderStatus: "Order Processed",
-				}
-				formatter.JSON(w, http.StatusOK, ord)
+				redisErrorResponse(formatter, w, err)
+				return
 			}
+			fmt.Println("Order: ", ord)
+			formatter.JSON(w, http.StatusOK, ord)
 		}
 	}
 }
 
-
 /*
 
 	-- Create Database Schema (DB User: root, DB Pass: cmpe281)
@@ -320,6 +246,13 @@ func gumballProcessOrdersHandler(formatter *render.Render) http.HandlerFunc {
 		  UNIQUE KEY serial_number (serial_number)
 		) ;
 
+		-- Created at startup if missing
+		CREATE TABLE gumball_orders (
+		  order_id varchar(36) NOT NULL,
+		  gumball_id bigint(20) NOT NULL,
+		  PRIMARY KEY (order_id)
+		) ;
+
 	-- Load Data
 
 		insert into gumball ( id, version, count_gumballs, model_number, serial_number ) 
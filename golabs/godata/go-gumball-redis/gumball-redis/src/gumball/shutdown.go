@@ -0,0 +1,98 @@
+/*
+	Gumball API in Go
+	Graceful Shutdown
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+	On SIGTERM or SIGINT the server stops accepting requests and
+	waits for in-flight requests.  Each order processor finishes
+	the order it is processing and reads no more entries; entries
+	it read but did not start stay pending and are reclaimed by
+	another replica.  The Redis client and MySQL pool are closed
+	last.
+
+	Environment:
+		SHUTDOWN_TIMEOUT	drain deadline (default 8s, under
+					the 10s docker stop grace period)
+*/
+
+// Shutdown Signal to Workers
+var shutdown = make(chan struct{})
+var workers_done sync.WaitGroup
+
+// Shutdown Deadline from Environment
+func shutdownConfig() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Second
+}
+
+func stopping() bool {
+	select {
+	case <-shutdown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Serve until SIGTERM or SIGINT, then drain
+func run(srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Println("Listening on ", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-sigs:
+		fmt.Println("Shutting Down: ", sig)
+	}
+
+	close(shutdown)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownConfig())
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println("Server Shutdown Error: ", err)
+	}
+	return drain(ctx)
+}
+
+// Wait for the processors, then close the connections
+// An entry still unacked at the deadline is reclaimed later.
+func drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workers_done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		fmt.Println("Shutdown Deadline Passed")
+	}
+	err := redis_client.Close()
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	fmt.Println("Connections Closed")
+	return err
+}
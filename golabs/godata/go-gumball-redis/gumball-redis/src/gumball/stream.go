@@ -0,0 +1,264 @@
+/*
+	Gumball API in Go
+	Redis Order Stream
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+	Every new order is appended to the gumball:orders:stream stream.
+	Each replica runs ORDER_WORKERS processors in the processors
+	consumer group.  A processor reads new entries with XREADGROUP,
+	takes a gumball from MySQL, finishes the order as processed (or
+	failed when the machine is empty), then acks and deletes the
+	entry.
+
+	An entry whose processor dies before acking stays pending.  Each
+	replica runs XAUTOCLAIM every ORDER_CLAIM_IDLE / 2 and takes over
+	entries that have been idle for ORDER_CLAIM_IDLE, so the orders
+	of a crashed replica are finished by the others.  Delivery is at
+	least once, but takeGumball records the order in MySQL with the
+	decrement, so an order reclaimed after its gumball was taken is
+	only finished, not given a second gumball.
+
+	Environment:
+		ORDER_WORKERS		processors per replica (default 2)
+		ORDER_CLAIM_IDLE	idle time before reclaiming (default 30s)
+
+	On shutdown the processors and reclaimer stop between entries;
+	see shutdown.go.
+
+	Streams need Redis 5.0 and XAUTOCLAIM Redis 6.2.  The vendored
+	go-redis predates both, so the commands are sent with NewCmd.
+*/
+
+var redis_stream = "gumball:orders:stream"
+var redis_group = "processors"
+
+var order_workers = envInt("ORDER_WORKERS", 2)
+var order_claim_idle = envDuration("ORDER_CLAIM_IDLE", 30*time.Second)
+
+// Entries per Read or Claim
+var stream_batch = 10
+
+// XREADGROUP Block, below the client's 3s read timeout
+var stream_block = 2 * time.Second
+
+// Wait after a Redis error before reading again
+var stream_retry_delay = time.Second
+
+type streamEntry struct {
+	Id    string
+	Order string
+}
+
+func streamAdd(order_id string) *redis.Cmd {
+	return redis.NewCmd("XADD", redis_stream, "*", "order", order_id)
+}
+
+func millisArg(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// Start the Processors and Reclaimer of this Replica
+func order_processors() {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gumball"
+	}
+	workers_done.Add(order_workers + 1)
+	for i := 0; i < order_workers; i++ {
+		go order_processor(fmt.Sprintf("%s-%d", host, i))
+	}
+	go order_reclaimer(host + "-claim")
+}
+
+func order_processor(consumer string) {
+	defer workers_done.Done()
+	for {
+		err := createGroup()
+		for err == nil && !stopping() {
+			var entries []streamEntry
+			if entries, err = readGroup(consumer); err == nil {
+				processEntries(entries)
+			}
+		}
+		if err != nil {
+			fmt.Println("Redis Stream Error: ", consumer, err)
+		}
+		select {
+		case <-shutdown:
+			return
+		case <-time.After(stream_retry_delay):
+		}
+	}
+}
+
+func order_reclaimer(consumer string) {
+	defer workers_done.Done()
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-time.After(order_claim_idle / 2):
+		}
+		if err := reclaimEntries(consumer); err != nil {
+			fmt.Println("Redis Stream Error: ", consumer, err)
+		}
+	}
+}
+
+// Process Entries until Shutdown
+func processEntries(entries []streamEntry) {
+	for _, e := range entries {
+		if stopping() {
+			return
+		}
+		processEntry(e)
+	}
+}
+
+// Create the Consumer Group and Stream if Missing
+func createGroup() error {
+	err := redis_client.Process(redis.NewCmd("XGROUP", "CREATE", redis_stream, redis_group, "0", "MKSTREAM"))
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// New Entries for consumer, or none after stream_block
+func readGroup(consumer string) ([]streamEntry, error) {
+	cmd := redis.NewCmd("XREADGROUP", "GROUP", redis_group, consumer,
+		"COUNT", stream_batch, "BLOCK", millisArg(stream_block),
+		"STREAMS", redis_stream, ">")
+	err := redis_client.Process(cmd)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []streamEntry
+	streams, _ := cmd.Val().([]interface{})
+	for _, s := range streams {
+		if s, ok := s.([]interface{}); ok && len(s) == 2 {
+			entries = append(entries, parseEntries(s[1])...)
+		}
+	}
+	return entries, nil
+}
+
+// Take over Entries Idle for order_claim_idle
+func reclaimEntries(consumer string) error {
+	start := "0-0"
+	for {
+		cmd := redis.NewCmd("XAUTOCLAIM", redis_stream, redis_group, consumer,
+			millisArg(order_claim_idle), start, "COUNT", stream_batch)
+		if err := redis_client.Process(cmd); err != nil {
+			return err
+		}
+		reply, _ := cmd.Val().([]interface{})
+		if len(reply) < 2 {
+			return errors.New("unexpected XAUTOCLAIM reply")
+		}
+		entries := parseEntries(reply[1])
+		for _, e := range entries {
+			fmt.Println("Reclaimed Order: ", e.Order)
+		}
+		processEntries(entries)
+		start, _ = reply[0].(string)
+		if start == "" || start == "0-0" || stopping() {
+			return nil
+		}
+	}
+}
+
+// Entries as [id, [field, value, ...]]
+// A deleted entry has no fields and an empty Order.
+func parseEntries(v interface{}) []streamEntry {
+	list, _ := v.([]interface{})
+	var entries []streamEntry
+	for _, item := range list {
+		item, ok := item.([]interface{})
+		if !ok || len(item) != 2 {
+			continue
+		}
+		e := streamEntry{}
+		e.Id, _ = item[0].(string)
+		fields, _ := item[1].([]interface{})
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i] == "order" {
+				e.Order, _ = fields[i+1].(string)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Process One Order and Ack its Entry
+// The entry stays pending after a Redis or MySQL error, to be
+// reclaimed later.
+func processEntry(e streamEntry) {
+	if err := processOrder(e.Order); err != nil {
+		fmt.Println("Order Failed: ", e.Order, err)
+		return
+	}
+	_, err := redis_client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Process(redis.NewCmd("XACK", redis_stream, redis_group, e.Id))
+		pipe.Process(redis.NewCmd("XDEL", redis_stream, e.Id))
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Redis Stream Error: ", err)
+	}
+}
+
+// Take a Gumball and Finish the Order
+// Unknown and finished orders are skipped, and running it again
+// for an order does not take a second gumball.
+func processOrder(id string) error {
+	ord, err := getOrder(id)
+	if err == redis.Nil {
+		fmt.Println("Order Not Found: ", id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ord.OrderStatus != order_placed {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mysql_timeout)
+	defer cancel()
+	status := order_processed
+	err = takeGumball(ctx, id)
+	// An update that timed out may still have been applied
+	if err != errOutOfStock {
+		invalidateMachine()
+	}
+	switch err {
+	case nil:
+	case errOutOfStock:
+		status = order_failed
+	default:
+		return err
+	}
+	if err := finishOrder(id, status); err != nil && err != redis.Nil {
+		return err
+	}
+	fmt.Println("Order: ", id, status)
+	return nil
+}
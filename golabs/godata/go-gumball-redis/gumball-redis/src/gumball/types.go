@@ -5,6 +5,10 @@
 
 package main
 
+import (
+	"time"
+)
+
 type gumballMachine struct {
 	Id             	int 	
 	CountGumballs   int    	
@@ -15,6 +19,9 @@ type gumballMachine struct {
 type order struct {
 	Id             	string 	
 	OrderStatus 	string	
+	SerialNumber	string
+	CreatedAt	time.Time
+	ProcessedAt	*time.Time	`json:",omitempty"`
 }
 
 //var orders map[string] order
@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -18,8 +19,6 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/unrolled/render"
 	"github.com/satori/go.uuid"
-    "database/sql"
-	_ "github.com/go-sql-driver/mysql"
 )
 
 /*
@@ -173,34 +172,18 @@ func init() {
 	server3 = os.Getenv("RIAK3")
 
 	// MySQL Setup	
-	db, err := sql.Open("mysql", mysql_connect)
+	pool, err := openDB(mysql_connect)
 	if err != nil {
 		log.Fatal(err)
+	}
+	db = pool
+	ctx, cancel := context.WithTimeout(context.Background(), mysql_timeout)
+	defer cancel()
+	if m, err := getGumball(ctx); err != nil {
+		fmt.Println("MySQL Ping Error: ", err)
 	} else {
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		rows, err := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer rows.Close()
-		for rows.Next() {
-			err := rows.Scan(&id, &count, &model, &serial)
-			if err != nil {
-				log.Fatal(err)
-			}
-			log.Println("MySQL Ping: ", id, count, model, serial)
-		}
-		err = rows.Err()
-		if err != nil {
-			log.Fatal(err)
-		}
+		log.Println("MySQL Ping: ", m)
 	}
-	defer db.Close()
 
 	// Riak KV Setup	
 	c1 := NewClient(server1)
@@ -258,33 +241,14 @@ func pingHandler(formatter *render.Render) http.HandlerFunc {
 // API Gumball Machine Handler
 func gumballHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		result, err := getGumball(ctx)
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
-		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
@@ -292,45 +256,25 @@ func gumballHandler(formatter *render.Render) http.HandlerFunc {
 // API Update Gumball Inventory
 func gumballUpdateHandler(formatter *render.Render) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-    	var m gumballMachine
-    	_ = json.NewDecoder(req.Body).Decode(&m)		
-    	fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
-	
-		var (
-			id int
-			count int
-			model string
-			serial string
-		)
-		db, err := sql.Open("mysql", mysql_connect)
-		defer db.Close()
-		stmt, err := db.Prepare("update gumball set count_gumballs = ? where id = ?")
-		if err != nil {
-			log.Fatal(err)
-		}
-		_, err = stmt.Exec( m.CountGumballs, 1)
-		if err != nil {
-			log.Fatal(err)
+		var m gumballMachine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
 		}
+		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
 
-		if err != nil {
-			log.Fatal(err)
-		} else {
-			rows, _ := db.Query("select id, count_gumballs, model_number, serial_number from gumball where id = ?", 1)
-			defer rows.Close()
-			for rows.Next() {
-				rows.Scan(&id, &count, &model, &serial)
-				log.Println(id, count, model, serial)
-			}
+		ctx, cancel := dbContext(req)
+		defer cancel()
+		if err := setGumballCount(ctx, m.CountGumballs); err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-		result := gumballMachine {
-			Id : id,
-			CountGumballs : count,
-			ModelNumber : model,
-			SerialNumber : serial,
+		result, err := getGumball(ctx)
+		if err != nil {
+			dbErrorResponse(formatter, w, ctx, err)
+			return
 		}
-
-        fmt.Println("Gumball Machine:", result )
+		fmt.Println("Gumball Machine:", result)
 		formatter.JSON(w, http.StatusOK, result)
 	}
 }
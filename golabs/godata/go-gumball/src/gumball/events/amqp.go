@@ -0,0 +1,194 @@
+/*
+	Gumball API in Go
+	RabbitMQ Event Publisher
+*/
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/streadway/amqp"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default Topic Exchange
+const Exchange = "gumball.events"
+
+// Publisher Tuning
+var (
+	// Events waiting to be sent; more are dropped
+	publishBuffer = 1000
+	// Limit on connecting to the broker
+	dialTimeout = 5 * time.Second
+	// Wait before sending again after a broker error
+	retryDelay = time.Second
+	// Wait in Close for queued events to be sent
+	closeTimeout = 5 * time.Second
+)
+
+// ErrQueueFull is returned by Publish while the broker is too slow
+// or down and publishBuffer events are already waiting.
+var ErrQueueFull = errors.New("events: publish queue full")
+
+var errClosed = errors.New("events: publisher closed")
+
+// AMQPPublisher publishes persistent events on a durable topic
+// exchange.  Publish only queues the event, so API requests never
+// wait on the broker; one goroutine sends queued events in order,
+// opening the connection on demand and again after it fails.
+type AMQPPublisher struct {
+	url      string
+	exchange string
+	queue    chan Event
+	closing  chan struct{}
+	done     chan struct{}
+	once     sync.Once
+
+	// Used only by the sending goroutine
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+func NewAMQPPublisher(url, exchange string) *AMQPPublisher {
+	p := &AMQPPublisher{
+		url:      url,
+		exchange: exchange,
+		queue:    make(chan Event, publishBuffer),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *AMQPPublisher) Publish(e Event) error {
+	select {
+	case <-p.closing:
+		return errClosed
+	default:
+	}
+	select {
+	case p.queue <- e:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Send Queued Events until Close
+func (p *AMQPPublisher) run() {
+	defer close(p.done)
+	defer p.closeConn()
+	for {
+		select {
+		case e := <-p.queue:
+			p.sendRetrying(e)
+		case <-p.closing:
+			// Flush what is queued, while the broker answers
+			for {
+				select {
+				case e := <-p.queue:
+					if err := p.send(e); err != nil {
+						fmt.Println("Event Dropped: ", e.Type, e.Id, err)
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Send an Event, Retrying after Broker Errors until Close
+func (p *AMQPPublisher) sendRetrying(e Event) {
+	for {
+		err := p.send(e)
+		if err == nil {
+			return
+		}
+		fmt.Println("Event Publish Error: ", e.Type, e.Id, err)
+		select {
+		case <-p.closing:
+			fmt.Println("Event Dropped: ", e.Type, e.Id)
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (p *AMQPPublisher) send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if p.ch == nil {
+		if err := p.open(); err != nil {
+			return err
+		}
+	}
+	err = p.ch.Publish(p.exchange, e.RoutingKey(), false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    e.Id,
+		Timestamp:    e.Time,
+		Type:         e.Type,
+		Body:         body,
+	})
+	if err != nil {
+		p.closeConn()
+	}
+	return err
+}
+
+func (p *AMQPPublisher) open() error {
+	conn, err := amqp.DialConfig(p.url, amqp.Config{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.DialTimeout(network, addr, dialTimeout)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err == nil {
+		err = ch.ExchangeDeclare(
+			p.exchange, // name
+			"topic",    // kind
+			true,       // durable
+			false,      // delete when unused
+			false,      // internal
+			false,      // no-wait
+			nil,        // arguments
+		)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	p.conn, p.ch = conn, ch
+	return nil
+}
+
+// Close sends the queued events, waiting up to closeTimeout, and
+// closes the connection.
+func (p *AMQPPublisher) Close() error {
+	p.once.Do(func() { close(p.closing) })
+	select {
+	case <-p.done:
+		return nil
+	case <-time.After(closeTimeout):
+		return errors.New("events: close timed out")
+	}
+}
+
+func (p *AMQPPublisher) closeConn() {
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn, p.ch = nil, nil
+}
@@ -0,0 +1,96 @@
+/*
+	Gumball API in Go
+	Domain Events
+*/
+
+package events
+
+import (
+	"encoding/json"
+	"github.com/satori/go.uuid"
+	"strings"
+	"time"
+)
+
+/*
+	Every change made through the API is published as a JSON event
+	on the gumball.events topic exchange.  The routing key is
+
+		<entity>.<action>.<serial number>
+
+	so subscribers can bind "order.#", "*.*.1234998871109" and so
+	on.  Version is the version of the event's Data layout and goes
+	up whenever a field is removed or changes meaning; new fields
+	are added without a new version.
+*/
+
+// Event Types
+const (
+	OrderPlaced       = "OrderPlaced"
+	OrderProcessed    = "OrderProcessed"
+	OrderCancelled    = "OrderCancelled"
+	InventoryChanged  = "InventoryChanged"
+	MachineRegistered = "MachineRegistered"
+)
+
+// Current Version of Every Event Type
+const Version = 1
+
+// Routing Key Prefixes
+var routes = map[string]string{
+	OrderPlaced:       "order.placed",
+	OrderProcessed:    "order.processed",
+	OrderCancelled:    "order.cancelled",
+	InventoryChanged:  "inventory.changed",
+	MachineRegistered: "machine.registered",
+}
+
+type Event struct {
+	Id           string
+	Type         string
+	Version      int
+	Time         time.Time
+	SerialNumber string
+	Data         json.RawMessage
+}
+
+// New event about the machine with the given serial number.
+func New(kind, serial string, data interface{}) (Event, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		Id:           uuid.NewV4().String(),
+		Type:         kind,
+		Version:      Version,
+		Time:         time.Now().UTC(),
+		SerialNumber: serial,
+		Data:         body,
+	}, nil
+}
+
+// Routing Key of an Event
+// Dots in the serial number would split the key, so they are
+// replaced with underscores.
+func (e Event) RoutingKey() string {
+	route, ok := routes[e.Type]
+	if !ok {
+		route = "unknown." + strings.ToLower(e.Type)
+	}
+	return route + "." + strings.Replace(e.SerialNumber, ".", "_", -1)
+}
+
+// Event Publisher
+// Publish is called after the change is stored, in the request,
+// so it must not wait on the broker.
+type Publisher interface {
+	Publish(e Event) error
+	Close() error
+}
+
+// Publisher for Deployments without a Broker
+type Discard struct{}
+
+func (Discard) Publish(e Event) error { return nil }
+func (Discard) Close() error          { return nil }
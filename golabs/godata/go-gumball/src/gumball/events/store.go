@@ -0,0 +1,111 @@
+/*
+	Gumball API in Go
+	Event Publishing Store
+*/
+
+package events
+
+import (
+	"fmt"
+	"gumball/store"
+)
+
+// Store Wrapper Publishing an Event for Each Change
+// Events are published after the change is stored; a publish
+// error is logged and does not fail the change.
+type Store struct {
+	store.Store
+	events Publisher
+}
+
+func NewStore(s store.Store, p Publisher) *Store {
+	return &Store{Store: s, events: p}
+}
+
+func (s *Store) RegisterMachine(m store.Machine) (store.Machine, error) {
+	m, err := s.Store.RegisterMachine(m)
+	if err == nil {
+		s.publish(MachineRegistered, m.SerialNumber, m)
+	}
+	return m, err
+}
+
+func (s *Store) UpdateInventory(serial string, count int) (store.Machine, error) {
+	m, err := s.Store.UpdateInventory(serial, count)
+	if err == nil {
+		s.publish(InventoryChanged, serial, m)
+	}
+	return m, err
+}
+
+func (s *Store) UpdateInventoryIf(serial string, version int64, count int) (store.Machine, error) {
+	m, err := s.Store.UpdateInventoryIf(serial, version, count)
+	if err == nil {
+		s.publish(InventoryChanged, serial, m)
+	}
+	return m, err
+}
+
+func (s *Store) UpdateInventoryFenced(serial string, token, version int64, count int) (store.Machine, error) {
+	m, err := s.Store.UpdateInventoryFenced(serial, token, version, count)
+	if err == nil {
+		s.publish(InventoryChanged, serial, m)
+	}
+	return m, err
+}
+
+func (s *Store) CreateOrder(ord store.Order) (store.Order, error) {
+	ord, err := s.Store.CreateOrder(ord)
+	if err == nil {
+		s.publish(OrderPlaced, ord.SerialNumber, ord)
+	}
+	return ord, err
+}
+
+// A failed order is published as processed with its status and
+// reason; a dispensed one is followed by InventoryChanged.
+func (s *Store) ProcessOrder(id string) (store.Order, error) {
+	ord, err := s.Store.ProcessOrder(id)
+	return s.processed(ord, err)
+}
+
+func (s *Store) ProcessOrderFenced(id string, token int64) (store.Order, error) {
+	ord, err := s.Store.ProcessOrderFenced(id, token)
+	return s.processed(ord, err)
+}
+
+func (s *Store) processed(ord store.Order, err error) (store.Order, error) {
+	if err != nil && err != store.ErrOutOfStock {
+		return ord, err
+	}
+	s.publish(OrderProcessed, ord.SerialNumber, ord)
+	if err == nil {
+		if m, err := s.Store.GetMachine(ord.SerialNumber); err == nil {
+			s.publish(InventoryChanged, m.SerialNumber, m)
+		}
+	}
+	return ord, err
+}
+
+func (s *Store) CancelOrder(id string) (store.Order, error) {
+	ord, err := s.Store.CancelOrder(id)
+	if err == nil {
+		s.publish(OrderCancelled, ord.SerialNumber, ord)
+	}
+	return ord, err
+}
+
+func (s *Store) Close() error {
+	s.events.Close()
+	return s.Store.Close()
+}
+
+func (s *Store) publish(kind, serial string, data interface{}) {
+	e, err := New(kind, serial, data)
+	if err == nil {
+		err = s.events.Publish(e)
+	}
+	if err != nil {
+		fmt.Println("Event Publish Error: ", kind, serial, err)
+	}
+}
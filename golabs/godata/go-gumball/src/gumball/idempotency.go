@@ -0,0 +1,96 @@
+/*
+	Gumball API in Go
+	Idempotency Keys for Orders
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/unrolled/render"
+	"gumball/store"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+/*
+	A client that retries POST /order (or POST /gumball/{serial}/order)
+	sends the same Idempotency-Key header each time.  The first request
+	claims the key for the order it places; a replay with the same
+	machine and body gets that order back instead of a new one, and
+	reusing the key for a different request is refused with 422.
+	A replay that arrives while the first request is still placing
+	its order gets 409.  If no order appears within key_claim_lease
+	the first request is taken to have died, and the replay claims
+	the key and places the order itself.  Keys are forgotten after
+	IDEMPOTENCY_TTL.
+*/
+
+// Longest key the MySQL schema stores
+const max_idempotency_key = 255
+
+// How long a claim may go without its order before it is abandoned
+var key_claim_lease = 30 * time.Second
+
+// Claim key for a new order.  Returns false once a response
+// has been written: a replay, a conflict or an error.
+func claimOrderKey(formatter *render.Render, w http.ResponseWriter, req *http.Request, db store.Store, serial, key, id string, keyTTL time.Duration) bool {
+	if len(key) > max_idempotency_key {
+		formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Idempotency Key"})
+		return false
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Request"})
+		return false
+	}
+	fingerprint := requestFingerprint(serial, body)
+	k := store.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		OrderId:     id,
+	}
+	claimed, err := db.ClaimKey(k, keyTTL)
+	for err != nil {
+		if err != store.ErrExists {
+			errorResponse(formatter, w, err)
+			return false
+		}
+		if claimed.Fingerprint != fingerprint {
+			formatter.JSON(w, http.StatusUnprocessableEntity, struct{ Error string }{"Idempotency Key Reused"})
+			return false
+		}
+		ord, err := db.GetOrder(claimed.OrderId)
+		if err == nil {
+			fmt.Println("Replayed Order: ", ord)
+			w.Header().Set("Idempotent-Replayed", "true")
+			formatter.JSON(w, http.StatusOK, ord)
+			return false
+		}
+		if err != store.ErrNotFound {
+			errorResponse(formatter, w, err)
+			return false
+		}
+		if !claimed.ClaimedBefore(time.Now().Add(-key_claim_lease)) {
+			// The first request is still placing its order
+			formatter.JSON(w, http.StatusConflict, struct{ Error string }{"Order In Progress"})
+			return false
+		}
+		// The first request died before placing its order
+		fmt.Println("Abandoned Idempotency Key: ", claimed.Key)
+		claimed, err = db.ReclaimKey(claimed, k, keyTTL)
+	}
+	return true
+}
+
+// Requests match when they are for the same machine with the same body.
+func requestFingerprint(serial string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(serial))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,125 @@
+/*
+	Gumball API in Go
+	Distributed Inventory Locks
+*/
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+/*
+	Replicas behind Kong change the same machines, so inventory
+	changes are made while holding a lease on the machine's lock.
+	A lease lasts until it is released or its TTL runs out, so a
+	crashed holder cannot block the machine for long.
+
+	Every lease on a lock gets a larger fencing token than the one
+	before.  The token goes with each change to the store, which
+	refuses tokens older than the newest it has seen; a holder that
+	was paused past its TTL finds its changes rejected instead of
+	overwriting those of the next holder.
+
+	Lockers:
+		MemoryLocker	one process, for the memory backend and tests
+		RedisLocker	SET NX PX with an INCR token counter
+		MySQLLocker	GET_LOCK with a token table
+*/
+
+var (
+	ErrHeld    = errors.New("lock: held by another lease")
+	ErrExpired = errors.New("lock: lease expired")
+)
+
+// Lease on a Named Lock
+// Tokens start at 1.  Expires is this process's view of the end of
+// the lease.
+type Lease struct {
+	Name    string
+	Token   int64
+	Expires time.Time
+}
+
+// Locker
+//
+// TryAcquire returns ErrHeld while another lease on name is live.
+// Release ends a lease and returns ErrExpired if it had already
+// ended.
+type Locker interface {
+	TryAcquire(name string, ttl time.Duration) (Lease, error)
+	Release(l Lease) error
+	Close() error
+}
+
+// Wait between Attempts in Acquire
+var retry_delay = 20 * time.Millisecond
+
+// Acquire a Lease, Waiting while it is Held
+func Acquire(ctx context.Context, l Locker, name string, ttl time.Duration) (Lease, error) {
+	for {
+		lease, err := l.TryAcquire(name, ttl)
+		if err != ErrHeld {
+			return lease, err
+		}
+		select {
+		case <-ctx.Done():
+			return Lease{}, ErrHeld
+		case <-time.After(retry_delay):
+		}
+	}
+}
+
+// In-Process Locker
+type MemoryLocker struct {
+	mutex  sync.Mutex
+	leases map[string]Lease
+	tokens map[string]int64
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		leases: make(map[string]Lease),
+		tokens: make(map[string]int64),
+	}
+}
+
+func (l *MemoryLocker) TryAcquire(name string, ttl time.Duration) (Lease, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if held, ok := l.leases[name]; ok && time.Now().Before(held.Expires) {
+		return Lease{}, ErrHeld
+	}
+	l.tokens[name]++
+	lease := Lease{Name: name, Token: l.tokens[name], Expires: time.Now().Add(ttl)}
+	l.leases[name] = lease
+	return lease, nil
+}
+
+// Floor raises the counter for name so its next token is larger
+// than token.
+func (l *MemoryLocker) Floor(name string, token int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.tokens[name] < token {
+		l.tokens[name] = token
+	}
+}
+
+func (l *MemoryLocker) Release(lease Lease) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	held, ok := l.leases[lease.Name]
+	if !ok || held.Token != lease.Token || !time.Now().Before(held.Expires) {
+		return ErrExpired
+	}
+	delete(l.leases, lease.Name)
+	return nil
+}
+
+func (l *MemoryLocker) Close() error {
+	return nil
+}
@@ -0,0 +1,208 @@
+package lock_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"gumball/lock"
+	"gumball/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	testLocker(t, lock.NewMemoryLocker(), func(d time.Duration) { time.Sleep(d) })
+}
+
+// Redis Stand-In
+// miniredis only expires keys when its clock is moved.
+func TestRedisLocker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	l := lock.NewRedisLocker(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	testLocker(t, l, mr.FastForward)
+}
+
+func testLocker(t *testing.T, l lock.Locker, wait func(time.Duration)) {
+	defer l.Close()
+	ttl := 50 * time.Millisecond
+
+	first, err := l.TryAcquire("machine:1", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Token < 1 {
+		t.Errorf("first token = %d, want at least 1", first.Token)
+	}
+	if _, err := l.TryAcquire("machine:1", ttl); err != lock.ErrHeld {
+		t.Errorf("TryAcquire(held) = %v, want ErrHeld", err)
+	}
+	if _, err := l.TryAcquire("machine:2", ttl); err != nil {
+		t.Errorf("TryAcquire(other lock) = %v", err)
+	}
+	if err := l.Release(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(first); err != lock.ErrExpired {
+		t.Errorf("second Release = %v, want ErrExpired", err)
+	}
+
+	second, err := l.TryAcquire("machine:1", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Token <= first.Token {
+		t.Errorf("second token = %d, want more than %d", second.Token, first.Token)
+	}
+
+	// An expired lease is free for the next holder
+	wait(2 * ttl)
+	third, err := l.TryAcquire("machine:1", ttl)
+	if err != nil {
+		t.Fatalf("TryAcquire(expired) = %v", err)
+	}
+	if third.Token <= second.Token {
+		t.Errorf("third token = %d, want more than %d", third.Token, second.Token)
+	}
+	if err := l.Release(second); err != lock.ErrExpired {
+		t.Errorf("Release(expired) = %v, want ErrExpired", err)
+	}
+	if err := l.Release(third); err != nil {
+		t.Errorf("Release(third) = %v", err)
+	}
+}
+
+func TestAcquireWaits(t *testing.T) {
+	l := lock.NewMemoryLocker()
+	held, err := l.TryAcquire("machine:1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := lock.Acquire(ctx, l, "machine:1", time.Minute); err != lock.ErrHeld {
+		t.Errorf("Acquire(held) = %v, want ErrHeld", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { l.Release(held) })
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := lock.Acquire(ctx, l, "machine:1", time.Minute); err != nil {
+		t.Errorf("Acquire(released) = %v", err)
+	}
+}
+
+// A holder paused past its lease cannot change the machine after
+// the next holder has.
+func TestStaleHolder(t *testing.T) {
+	l := lock.NewMemoryLocker()
+	db := store.NewMemoryStore()
+	s := lock.NewStore(db, l, time.Second)
+	if _, err := s.RegisterMachine(store.DefaultMachine("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	paused, err := l.TryAcquire("machine:1", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.GetMachine("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := s.UpdateInventory("1", 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UpdateInventoryFenced("1", paused.Token, m.Version+1, 500); err != store.ErrStaleToken {
+		t.Errorf("UpdateInventoryFenced(paused) = %v, want ErrStaleToken", err)
+	}
+	if m, _ := s.GetMachine("1"); m.CountGumballs != 10 {
+		t.Errorf("CountGumballs = %d, want 10", m.CountGumballs)
+	}
+}
+
+// A new process, or another replica, locking in memory starts its
+// tokens past the fence the store already holds.
+func TestMemoryLockerWithExistingStore(t *testing.T) {
+	db := store.NewMemoryStore()
+	first := lock.NewStore(db, lock.NewMemoryLocker(), time.Second)
+	if _, err := first.RegisterMachine(store.DefaultMachine("1")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := first.UpdateInventory("1", 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restarted := lock.NewStore(db, lock.NewMemoryLocker(), time.Second)
+	if _, err := restarted.UpdateInventory("1", 50); err != nil {
+		t.Fatalf("UpdateInventory after restart = %v", err)
+	}
+	if _, err := restarted.CreateOrder(store.Order{Id: "a", SerialNumber: "1", OrderStatus: store.OrderPlaced}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.ProcessOrder("a"); err != nil {
+		t.Fatalf("ProcessOrder after restart = %v", err)
+	}
+
+	// The two take turns
+	for i := 0; i < 3; i++ {
+		if _, err := first.UpdateInventory("1", 10); err != nil {
+			t.Fatalf("first UpdateInventory = %v", err)
+		}
+		if _, err := restarted.UpdateInventory("1", 10); err != nil {
+			t.Fatalf("restarted UpdateInventory = %v", err)
+		}
+	}
+	if m, _ := db.GetMachine("1"); m.Fence != 11 {
+		t.Errorf("Fence = %d, want 11", m.Fence)
+	}
+}
+
+func TestConcurrentOrders(t *testing.T) {
+	s := lock.NewStore(store.NewMemoryStore(), lock.NewMemoryLocker(), time.Second)
+	if _, err := s.RegisterMachine(store.Machine{CountGumballs: 5, ModelNumber: "M102988", SerialNumber: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for _, id := range ids {
+		if _, err := s.CreateOrder(store.Order{Id: id, SerialNumber: "1", OrderStatus: store.OrderPlaced}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	dispensed := 0
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := s.ProcessOrder(id)
+			if err == store.ErrOutOfStock {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mutex.Lock()
+			dispensed++
+			mutex.Unlock()
+		}(id)
+	}
+	wg.Wait()
+	m, err := s.GetMachine("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispensed != 5 || m.CountGumballs != 0 {
+		t.Errorf("dispensed %d leaving %d, want 5 leaving 0", dispensed, m.CountGumballs)
+	}
+	if m.Fence < 1 {
+		t.Errorf("Fence = %d, want a lease token", m.Fence)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+	Gumball API in Go
+	MySQL Locks
+*/
+
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	_ "github.com/go-sql-driver/mysql"
+	"sync"
+	"time"
+)
+
+/*
+	GET_LOCK locks belong to a session, so each lease keeps one
+	connection from the pool until it ends.  The session's
+	wait_timeout is set to the lease TTL: if the holder stops, MySQL
+	closes the idle session and frees the lock, even if the holder
+	never runs again.  A timer also ends the lease locally.
+
+	Tokens come from the lock_tokens table, which
+	OpenMySQLLocker creates if it is missing:
+
+		CREATE TABLE lock_tokens (
+		  name varchar(64) NOT NULL,
+		  token bigint(20) NOT NULL,
+		  PRIMARY KEY (name)
+		) ;
+*/
+
+type MySQLLocker struct {
+	db     *sql.DB
+	mutex  sync.Mutex
+	leases map[leaseKey]*mysqlLease
+}
+
+type leaseKey struct {
+	name  string
+	token int64
+}
+
+type mysqlLease struct {
+	conn  *sql.Conn
+	timer *time.Timer
+}
+
+func OpenMySQLLocker(connect string) (*MySQLLocker, error) {
+	db, err := sql.Open("mysql", connect)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS lock_tokens (
+		  name varchar(64) NOT NULL,
+		  token bigint(20) NOT NULL,
+		  PRIMARY KEY (name)
+		)`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return NewMySQLLocker(db), nil
+}
+
+// NewMySQLLocker uses an open database.
+func NewMySQLLocker(db *sql.DB) *MySQLLocker {
+	return &MySQLLocker{db: db, leases: make(map[leaseKey]*mysqlLease)}
+}
+
+// GET_LOCK names are server-wide, so they share the gumball prefix.
+func mysqlLockName(name string) string {
+	return "gumball:" + name
+}
+
+func (l *MySQLLocker) TryAcquire(name string, ttl time.Duration) (Lease, error) {
+	ctx := context.Background()
+	expires := time.Now().Add(ttl)
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return Lease{}, err
+	}
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "select get_lock(?, 0)", mysqlLockName(name)).Scan(&locked); err != nil {
+		conn.Close()
+		return Lease{}, err
+	}
+	if locked.Int64 != 1 {
+		conn.Close()
+		return Lease{}, ErrHeld
+	}
+
+	var token int64
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	_, err = conn.ExecContext(ctx, "set session wait_timeout = ?", seconds)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "insert into lock_tokens ( name, token ) values ( ?, last_insert_id(1) ) on duplicate key update token = last_insert_id(token + 1)", name)
+	}
+	if err == nil {
+		err = conn.QueryRowContext(ctx, "select last_insert_id()").Scan(&token)
+	}
+	if err != nil {
+		releaseConn(conn, name)
+		return Lease{}, err
+	}
+
+	lease := Lease{Name: name, Token: token, Expires: expires}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.leases[leaseKey{name, token}] = &mysqlLease{
+		conn:  conn,
+		timer: time.AfterFunc(ttl, func() { l.Release(lease) }),
+	}
+	return lease, nil
+}
+
+func (l *MySQLLocker) Release(lease Lease) error {
+	key := leaseKey{lease.Name, lease.Token}
+	l.mutex.Lock()
+	held, ok := l.leases[key]
+	delete(l.leases, key)
+	l.mutex.Unlock()
+	if !ok {
+		return ErrExpired
+	}
+	held.timer.Stop()
+	return releaseConn(held.conn, lease.Name)
+}
+
+// Free the Lock and Return the Connection to the Pool
+// A connection that cannot be reset is dropped, which frees the
+// lock along with it.
+func releaseConn(conn *sql.Conn, name string) error {
+	defer conn.Close()
+	ctx := context.Background()
+	var released sql.NullInt64
+	err := conn.QueryRowContext(ctx, "select release_lock(?)", mysqlLockName(name)).Scan(&released)
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "set session wait_timeout = @@global.wait_timeout")
+	}
+	if err != nil {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		return err
+	}
+	if released.Int64 != 1 {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Close Releases Live Leases and the Database
+func (l *MySQLLocker) Close() error {
+	l.mutex.Lock()
+	var leases []Lease
+	for key := range l.leases {
+		leases = append(leases, Lease{Name: key.name, Token: key.token})
+	}
+	l.mutex.Unlock()
+	for _, lease := range leases {
+		l.Release(lease)
+	}
+	return l.db.Close()
+}
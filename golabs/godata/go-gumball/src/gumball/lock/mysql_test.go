@@ -0,0 +1,168 @@
+package lock_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"gumball/lock"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMySQLLocker(t *testing.T) {
+	db, err := sql.Open("fakelock", newFakeLockDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLocker(t, lock.NewMySQLLocker(db), func(d time.Duration) { time.Sleep(d) })
+}
+
+// MySQL Stand-In
+// A database/sql driver that understands exactly the statements
+// MySQLLocker issues.  GET_LOCK locks belong to the connection
+// that took them, and LAST_INSERT_ID(expr) is remembered per
+// connection, as in MySQL.
+func init() {
+	sql.Register("fakelock", fakeLockDriver{})
+}
+
+type fakeLockDB struct {
+	mutex  sync.Mutex
+	locks  map[string]*fakeLockConn
+	tokens map[string]int64
+}
+
+var fakeLockDBs = struct {
+	sync.Mutex
+	next int
+	dbs  map[string]*fakeLockDB
+}{dbs: make(map[string]*fakeLockDB)}
+
+// newFakeLockDB returns the data source name of a new database.
+func newFakeLockDB() string {
+	fakeLockDBs.Lock()
+	defer fakeLockDBs.Unlock()
+	fakeLockDBs.next++
+	name := fmt.Sprintf("locks%d", fakeLockDBs.next)
+	fakeLockDBs.dbs[name] = &fakeLockDB{locks: make(map[string]*fakeLockConn), tokens: make(map[string]int64)}
+	return name
+}
+
+type fakeLockDriver struct{}
+
+func (fakeLockDriver) Open(name string) (driver.Conn, error) {
+	fakeLockDBs.Lock()
+	defer fakeLockDBs.Unlock()
+	db, ok := fakeLockDBs.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("fake lock: unknown database %q", name)
+	}
+	return &fakeLockConn{db: db}, nil
+}
+
+type fakeLockConn struct {
+	db     *fakeLockDB
+	lastId int64
+}
+
+func (c *fakeLockConn) Prepare(query string) (driver.Stmt, error) {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	handler, ok := fakeLockStatements[query]
+	if !ok {
+		return nil, fmt.Errorf("fake lock: unsupported statement %q", query)
+	}
+	return &fakeLockStmt{conn: c, handler: handler}, nil
+}
+
+// Closing the session frees its locks.
+func (c *fakeLockConn) Close() error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for name, holder := range c.db.locks {
+		if holder == c {
+			delete(c.db.locks, name)
+		}
+	}
+	return nil
+}
+
+func (c *fakeLockConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("fake lock: no transactions")
+}
+
+type fakeLockHandler func(c *fakeLockConn, args []driver.Value) []driver.Value
+
+// Each statement returns its one row, or nil for none.
+var fakeLockStatements = map[string]fakeLockHandler{
+	"select get_lock(?, 0)": func(c *fakeLockConn, args []driver.Value) []driver.Value {
+		name := args[0].(string)
+		if holder, ok := c.db.locks[name]; ok && holder != c {
+			return []driver.Value{int64(0)}
+		}
+		c.db.locks[name] = c
+		return []driver.Value{int64(1)}
+	},
+	"select release_lock(?)": func(c *fakeLockConn, args []driver.Value) []driver.Value {
+		name := args[0].(string)
+		if c.db.locks[name] != c {
+			return []driver.Value{int64(0)}
+		}
+		delete(c.db.locks, name)
+		return []driver.Value{int64(1)}
+	},
+	"set session wait_timeout = ?": func(c *fakeLockConn, args []driver.Value) []driver.Value {
+		return nil
+	},
+	"set session wait_timeout = @@global.wait_timeout": func(c *fakeLockConn, args []driver.Value) []driver.Value {
+		return nil
+	},
+	"insert into lock_tokens ( name, token ) values ( ?, last_insert_id(1) ) on duplicate key update token = last_insert_id(token + 1)": func(c *fakeLockConn, args []driver.Value) []driver.Value {
+		name := args[0].(string)
+		c.db.tokens[name]++
+		c.lastId = c.db.tokens[name]
+		return nil
+	},
+	"select last_insert_id()": func(c *fakeLockConn, args []driver.Value) []driver.Value {
+		return []driver.Value{c.lastId}
+	},
+}
+
+type fakeLockStmt struct {
+	conn    *fakeLockConn
+	handler fakeLockHandler
+}
+
+func (s *fakeLockStmt) Close() error  { return nil }
+func (s *fakeLockStmt) NumInput() int { return -1 }
+
+func (s *fakeLockStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.db.mutex.Lock()
+	defer s.conn.db.mutex.Unlock()
+	s.handler(s.conn, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeLockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.db.mutex.Lock()
+	defer s.conn.db.mutex.Unlock()
+	return &fakeLockRows{row: s.handler(s.conn, args)}, nil
+}
+
+type fakeLockRows struct {
+	row []driver.Value
+}
+
+func (r *fakeLockRows) Columns() []string { return []string{"value"} }
+func (r *fakeLockRows) Close() error      { return nil }
+
+func (r *fakeLockRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
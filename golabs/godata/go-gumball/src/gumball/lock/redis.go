@@ -0,0 +1,96 @@
+/*
+	Gumball API in Go
+	Redis Locks
+*/
+
+package lock
+
+import (
+	"github.com/go-redis/redis"
+	"time"
+)
+
+/*
+	Keys:
+		gumball:lock:{name}		token of the live lease, expires with PX
+		gumball:lock:{name}:token	last token handed out
+
+	Leases end on the Redis clock, so a paused holder loses its
+	lease even if it never runs again.
+*/
+
+// Acquire if Free
+// KEYS[1] lock, KEYS[2] token counter, ARGV TTL in milliseconds
+// Returns 0 if the lock is held, else the new token
+var redis_acquire = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+local token = redis.call('INCR', KEYS[2])
+redis.call('SET', KEYS[1], token, 'PX', ARGV[1])
+return token
+`)
+
+// Release if still Held by the Token
+// KEYS[1] lock, ARGV token
+var redis_release = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type RedisLocker struct {
+	client *redis.Client
+}
+
+func OpenRedisLocker(connect string) (*RedisLocker, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     connect,
+		Password: "", // no password set
+		DB:       0,  // use default DB
+	})
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return NewRedisLocker(client), nil
+}
+
+// NewRedisLocker uses a connected client.
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+func lockKey(name string) string {
+	return "gumball:lock:" + name
+}
+
+func (l *RedisLocker) TryAcquire(name string, ttl time.Duration) (Lease, error) {
+	expires := time.Now().Add(ttl)
+	keys := []string{lockKey(name), lockKey(name) + ":token"}
+	res, err := redis_acquire.Run(l.client, keys, int64(ttl/time.Millisecond)).Result()
+	if err != nil {
+		return Lease{}, err
+	}
+	token, _ := res.(int64)
+	if token == 0 {
+		return Lease{}, ErrHeld
+	}
+	return Lease{Name: name, Token: token, Expires: expires}, nil
+}
+
+func (l *RedisLocker) Release(lease Lease) error {
+	res, err := redis_release.Run(l.client, []string{lockKey(lease.Name)}, lease.Token).Result()
+	if err != nil {
+		return err
+	}
+	if n, _ := res.(int64); n == 0 {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (l *RedisLocker) Close() error {
+	return l.client.Close()
+}
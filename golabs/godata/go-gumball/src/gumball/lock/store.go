@@ -0,0 +1,109 @@
+/*
+	Gumball API in Go
+	Locked Inventory Store
+*/
+
+package lock
+
+import (
+	"context"
+	"fmt"
+	"gumball/store"
+	"time"
+)
+
+// Store Wrapper Changing Inventory under a Machine Lock
+// UpdateInventory, UpdateInventoryIf and ProcessOrder hold a lease
+// on "machine:{serial}" and pass its token to the fenced store
+// methods.  A lease that is not free within the TTL returns ErrHeld.
+// UpdateInventory sets the count at the version it read under the
+// lease, so it only fails with store.ErrVersionMismatch if another
+// writer skips the lock.
+type Store struct {
+	store.Store
+	locks Locker
+	ttl   time.Duration
+}
+
+func NewStore(s store.Store, l Locker, ttl time.Duration) *Store {
+	return &Store{Store: s, locks: l, ttl: ttl}
+}
+
+func machineLock(serial string) string {
+	return "machine:" + serial
+}
+
+func (s *Store) UpdateInventory(serial string, count int) (store.Machine, error) {
+	lease, err := s.lease(serial)
+	if err != nil {
+		return store.Machine{}, err
+	}
+	defer s.release(lease)
+	m, err := s.Store.GetMachine(serial)
+	if err != nil {
+		return store.Machine{}, err
+	}
+	return s.Store.UpdateInventoryFenced(serial, lease.Token, m.Version, count)
+}
+
+func (s *Store) UpdateInventoryIf(serial string, version int64, count int) (store.Machine, error) {
+	lease, err := s.lease(serial)
+	if err != nil {
+		return store.Machine{}, err
+	}
+	defer s.release(lease)
+	return s.Store.UpdateInventoryFenced(serial, lease.Token, version, count)
+}
+
+// The order's machine is looked up before taking its lock.
+func (s *Store) ProcessOrder(id string) (store.Order, error) {
+	ord, err := s.Store.GetOrder(id)
+	if err != nil {
+		return store.Order{}, err
+	}
+	lease, err := s.lease(ord.SerialNumber)
+	if err != nil {
+		return store.Order{}, err
+	}
+	defer s.release(lease)
+	return s.Store.ProcessOrderFenced(id, lease.Token)
+}
+
+func (s *Store) Close() error {
+	if err := s.locks.Close(); err != nil {
+		fmt.Println("Locker Close Error: ", err)
+	}
+	return s.Store.Close()
+}
+
+// Tokens Counted in This Process
+// A MemoryLocker counts from 1 in each process, while a store that
+// outlives the process keeps the fence of an earlier process or of
+// another replica.  Before leasing from such a locker the machine's
+// fence is read and the count raised past it, so the store does
+// not refuse every change until the count catches up.
+type localLocker interface {
+	Locker
+	Floor(name string, token int64)
+}
+
+func (s *Store) lease(serial string) (Lease, error) {
+	if l, ok := s.locks.(localLocker); ok {
+		m, err := s.Store.GetMachine(serial)
+		if err != nil {
+			return Lease{}, err
+		}
+		l.Floor(machineLock(serial), m.Fence)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.ttl)
+	defer cancel()
+	return Acquire(ctx, s.locks, machineLock(serial), s.ttl)
+}
+
+// A lease that expired before release is only logged; the store
+// has already refused any change made after it.
+func (s *Store) release(lease Lease) {
+	if err := s.locks.Release(lease); err != nil {
+		fmt.Println("Lock Release Error: ", lease.Name, err)
+	}
+}
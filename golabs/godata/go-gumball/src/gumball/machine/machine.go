@@ -0,0 +1,133 @@
+/*
+	Gumball API in Go
+	Gumball Machine
+*/
+
+// Package machine models a coin-operated gumball machine using the
+// State pattern from the Restlet gumball service.  Quarters and the
+// crank live in the machine; gumballs come from an Inventory.
+package machine
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrEmpty is returned by Inventory.Release when no gumballs are left.
+var ErrEmpty = errors.New("machine: no gumballs left")
+
+// Inventory is the stock of gumballs behind a machine.
+type Inventory interface {
+	// Count returns the number of gumballs left.
+	Count() (int, error)
+	// Release takes one gumball and returns the number left.
+	Release() (int, error)
+}
+
+// Result of a machine action
+type Result struct {
+	SerialNumber  string
+	CountGumballs int
+	State         string
+	Message       string
+}
+
+type GumballMachine struct {
+	mutex     sync.Mutex
+	serial    string
+	inventory Inventory
+	count     int
+
+	soldOutState    State
+	noQuarterState  State
+	hasQuarterState State
+	soldState       State
+
+	state State
+}
+
+func NewGumballMachine(serial string, inventory Inventory) *GumballMachine {
+	m := &GumballMachine{serial: serial, inventory: inventory}
+	m.soldOutState = &soldOutState{machine: m}
+	m.noQuarterState = &noQuarterState{machine: m}
+	m.hasQuarterState = &hasQuarterState{machine: m}
+	m.soldState = &soldState{machine: m}
+	m.state = m.noQuarterState
+	return m
+}
+
+func (m *GumballMachine) InsertQuarter() (Result, error) {
+	return m.do(func() (string, error) {
+		return m.state.InsertQuarter(), nil
+	})
+}
+
+func (m *GumballMachine) EjectQuarter() (Result, error) {
+	return m.do(func() (string, error) {
+		return m.state.EjectQuarter(), nil
+	})
+}
+
+func (m *GumballMachine) TurnCrank() (Result, error) {
+	return m.do(func() (string, error) {
+		msg := m.state.TurnCrank()
+		dispensed, err := m.state.Dispense()
+		if err != nil {
+			return "", err
+		}
+		return msg + " " + dispensed, nil
+	})
+}
+
+// Status returns the current state without acting on the machine.
+func (m *GumballMachine) Status() (Result, error) {
+	return m.do(func() (string, error) {
+		return "Machine is " + m.state.String(), nil
+	})
+}
+
+// Run one action against the latest inventory count.
+func (m *GumballMachine) do(action func() (string, error)) (Result, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	count, err := m.inventory.Count()
+	if err != nil {
+		return Result{}, err
+	}
+	m.refill(count)
+	msg, err := action()
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		SerialNumber:  m.serial,
+		CountGumballs: m.count,
+		State:         m.state.String(),
+		Message:       msg,
+	}, nil
+}
+
+// Track inventory changes made outside the machine, such as
+// a PUT /gumball refill.  A quarter already inserted is kept.
+func (m *GumballMachine) refill(count int) {
+	m.count = count
+	if count > 0 && m.state == m.soldOutState {
+		m.state = m.noQuarterState
+	}
+	if count == 0 && m.state == m.noQuarterState {
+		m.state = m.soldOutState
+	}
+}
+
+func (m *GumballMachine) setState(state State) {
+	m.state = state
+}
+
+func (m *GumballMachine) releaseBall() error {
+	count, err := m.inventory.Release()
+	if err != nil {
+		return err
+	}
+	m.count = count
+	return nil
+}
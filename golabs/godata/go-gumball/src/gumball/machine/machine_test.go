@@ -0,0 +1,109 @@
+package machine
+
+import (
+	"errors"
+	"testing"
+)
+
+// Inventory Stand-In
+type fakeInventory struct {
+	count int
+	err   error
+}
+
+func (i *fakeInventory) Count() (int, error) {
+	return i.count, nil
+}
+
+func (i *fakeInventory) Release() (int, error) {
+	if i.err != nil {
+		return 0, i.err
+	}
+	if i.count == 0 {
+		return 0, ErrEmpty
+	}
+	i.count--
+	return i.count, nil
+}
+
+type step struct {
+	action func(*GumballMachine) (Result, error)
+	state  string
+	msg    string
+}
+
+var (
+	insert = (*GumballMachine).InsertQuarter
+	eject  = (*GumballMachine).EjectQuarter
+	crank  = (*GumballMachine).TurnCrank
+)
+
+func runSteps(t *testing.T, m *GumballMachine, steps []step) {
+	for i, s := range steps {
+		res, err := s.action(m)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if res.State != s.state || res.Message != s.msg {
+			t.Errorf("step %d = %q %q, want %q %q", i, res.State, res.Message, s.state, s.msg)
+		}
+	}
+}
+
+func TestBuyGumball(t *testing.T) {
+	inv := &fakeInventory{count: 2}
+	m := NewGumballMachine("1234998871109", inv)
+	runSteps(t, m, []step{
+		{crank, "waiting for quarter", "You turned, but there's no quarter You need to pay first"},
+		{eject, "waiting for quarter", "You haven't inserted a quarter"},
+		{insert, "waiting for turn of crank", "You inserted a quarter"},
+		{insert, "waiting for turn of crank", "You can't insert another quarter"},
+		{eject, "waiting for quarter", "Quarter returned"},
+		{insert, "waiting for turn of crank", "You inserted a quarter"},
+		{crank, "waiting for quarter", "You turned... A gumball comes rolling out the slot..."},
+		{insert, "waiting for turn of crank", "You inserted a quarter"},
+		{crank, "sold out", "You turned... A gumball comes rolling out the slot... Oops, out of gumballs!"},
+		{insert, "sold out", "You can't insert a quarter, the machine is sold out"},
+		{crank, "sold out", "You turned, but there are no gumballs No gumball dispensed"},
+	})
+	if inv.count != 0 {
+		t.Errorf("inventory = %d, want 0", inv.count)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	inv := &fakeInventory{count: 0}
+	m := NewGumballMachine("1234998871109", inv)
+	runSteps(t, m, []step{
+		{insert, "sold out", "You can't insert a quarter, the machine is sold out"},
+	})
+	inv.count = 5
+	runSteps(t, m, []step{
+		{insert, "waiting for turn of crank", "You inserted a quarter"},
+	})
+
+	// Emptied by someone else while a quarter is in the machine
+	inv.count = 0
+	runSteps(t, m, []step{
+		{crank, "sold out", "You turned... Oops, out of gumballs! Quarter returned"},
+	})
+}
+
+func TestReleaseError(t *testing.T) {
+	inv := &fakeInventory{count: 5}
+	m := NewGumballMachine("1234998871109", inv)
+	if _, err := m.InsertQuarter(); err != nil {
+		t.Fatal(err)
+	}
+	inv.err = errors.New("storage down")
+	if _, err := m.TurnCrank(); err != inv.err {
+		t.Fatalf("TurnCrank = %v, want %v", err, inv.err)
+	}
+	res, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.State != "waiting for turn of crank" || res.CountGumballs != 5 {
+		t.Errorf("after failed crank = %+v", res)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+	Gumball API in Go
+	Gumball Machine States
+*/
+
+package machine
+
+/*
+	State Pattern (ported from restlet/gumball_v3)
+
+		NoQuarter --insertQuarter--> HasQuarter --turnCrank--> Sold
+		    ^                            |                      |
+		    +--------ejectQuarter--------+                   dispense
+		    ^                                                   |
+		    +----------------- gumballs left -------------------+
+		                                                        |
+		SoldOut <------------- no gumballs left ----------------+
+*/
+
+type State interface {
+	InsertQuarter() string
+	EjectQuarter() string
+	TurnCrank() string
+	Dispense() (string, error)
+	String() string
+}
+
+// No Quarter State
+type noQuarterState struct {
+	machine *GumballMachine
+}
+
+func (s *noQuarterState) InsertQuarter() string {
+	s.machine.setState(s.machine.hasQuarterState)
+	return "You inserted a quarter"
+}
+
+func (s *noQuarterState) EjectQuarter() string {
+	return "You haven't inserted a quarter"
+}
+
+func (s *noQuarterState) TurnCrank() string {
+	return "You turned, but there's no quarter"
+}
+
+func (s *noQuarterState) Dispense() (string, error) {
+	return "You need to pay first", nil
+}
+
+func (s *noQuarterState) String() string {
+	return "waiting for quarter"
+}
+
+// Has Quarter State
+type hasQuarterState struct {
+	machine *GumballMachine
+}
+
+func (s *hasQuarterState) InsertQuarter() string {
+	return "You can't insert another quarter"
+}
+
+func (s *hasQuarterState) EjectQuarter() string {
+	s.machine.setState(s.machine.noQuarterState)
+	return "Quarter returned"
+}
+
+func (s *hasQuarterState) TurnCrank() string {
+	s.machine.setState(s.machine.soldState)
+	return "You turned..."
+}
+
+func (s *hasQuarterState) Dispense() (string, error) {
+	return "No gumball dispensed", nil
+}
+
+func (s *hasQuarterState) String() string {
+	return "waiting for turn of crank"
+}
+
+// Sold State
+type soldState struct {
+	machine *GumballMachine
+}
+
+func (s *soldState) InsertQuarter() string {
+	return "Please wait, we're already giving you a gumball"
+}
+
+func (s *soldState) EjectQuarter() string {
+	return "Sorry, you already turned the crank"
+}
+
+func (s *soldState) TurnCrank() string {
+	return "Turning twice doesn't get you another gumball!"
+}
+
+func (s *soldState) Dispense() (string, error) {
+	err := s.machine.releaseBall()
+	if err == ErrEmpty {
+		s.machine.count = 0
+		s.machine.setState(s.machine.soldOutState)
+		return "Oops, out of gumballs! Quarter returned", nil
+	}
+	if err != nil {
+		// Give the customer another try at the crank
+		s.machine.setState(s.machine.hasQuarterState)
+		return "", err
+	}
+	if s.machine.count > 0 {
+		s.machine.setState(s.machine.noQuarterState)
+		return "A gumball comes rolling out the slot...", nil
+	}
+	s.machine.setState(s.machine.soldOutState)
+	return "A gumball comes rolling out the slot... Oops, out of gumballs!", nil
+}
+
+func (s *soldState) String() string {
+	return "dispensing a gumball"
+}
+
+// Sold Out State
+type soldOutState struct {
+	machine *GumballMachine
+}
+
+func (s *soldOutState) InsertQuarter() string {
+	return "You can't insert a quarter, the machine is sold out"
+}
+
+func (s *soldOutState) EjectQuarter() string {
+	return "You can't eject, you haven't inserted a quarter yet"
+}
+
+func (s *soldOutState) TurnCrank() string {
+	return "You turned, but there are no gumballs"
+}
+
+func (s *soldOutState) Dispense() (string, error) {
+	return "No gumball dispensed", nil
+}
+
+func (s *soldOutState) String() string {
+	return "sold out"
+}
@@ -0,0 +1,83 @@
+/*
+	Gumball API in Go
+	Gumball Machine State over REST
+*/
+
+package main
+
+import (
+	"github.com/satori/go.uuid"
+	"gumball/machine"
+	"gumball/store"
+	"sync"
+)
+
+// Gumball Machines by Serial Number
+// Quarter and crank state is kept in this process; the
+// gumballs themselves come from the store.
+type machineRegistry struct {
+	mutex    sync.Mutex
+	db       store.Store
+	machines map[string]*machine.GumballMachine
+}
+
+func newMachineRegistry(db store.Store) *machineRegistry {
+	return &machineRegistry{
+		db:       db,
+		machines: make(map[string]*machine.GumballMachine),
+	}
+}
+
+// Lookup returns store.ErrNotFound for machines that are not
+// registered.  A retired machine loses any quarter it was holding.
+func (r *machineRegistry) lookup(serial string) (*machine.GumballMachine, error) {
+	_, err := r.db.GetMachine(serial)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err == store.ErrNotFound {
+		delete(r.machines, serial)
+	}
+	if err != nil {
+		return nil, err
+	}
+	gm, ok := r.machines[serial]
+	if !ok {
+		gm = machine.NewGumballMachine(serial, storeInventory{r.db, serial})
+		r.machines[serial] = gm
+	}
+	return gm, nil
+}
+
+// Store Inventory
+// Each gumball released is recorded as a dispensed order.
+type storeInventory struct {
+	db     store.Store
+	serial string
+}
+
+func (i storeInventory) Count() (int, error) {
+	m, err := i.db.GetMachine(i.serial)
+	if err != nil {
+		return 0, err
+	}
+	return m.CountGumballs, nil
+}
+
+func (i storeInventory) Release() (int, error) {
+	ord, err := i.db.CreateOrder(store.Order{
+		Id:           uuid.NewV4().String(),
+		SerialNumber: i.serial,
+		OrderStatus:  store.OrderPlaced,
+	})
+	if err != nil {
+		return 0, err
+	}
+	_, err = i.db.ProcessOrder(ord.Id)
+	if err == store.ErrOutOfStock {
+		return 0, machine.ErrEmpty
+	}
+	if err != nil {
+		return 0, err
+	}
+	return i.Count()
+}
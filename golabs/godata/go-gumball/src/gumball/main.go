@@ -0,0 +1,194 @@
+/*
+	Gumball API in Go
+	Pluggable Storage Backends
+*/
+
+package main
+
+import (
+	"fmt"
+	"gumball/events"
+	"gumball/lock"
+	"gumball/ratelimit"
+	"gumball/store"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+
+	port := os.Getenv("PORT")
+	if len(port) == 0 {
+		port = "3000"
+	}
+
+	cfg := storeConfig()
+	db, err := store.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	locks, err := inventoryLocker(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if locks != nil {
+		db = lock.NewStore(db, locks, lockTTL())
+	}
+	db = events.NewStore(db, eventPublisher())
+	limits, err := rateLimits(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{Addr: ":" + port, Handler: NewServer(db, cfg.Serial, keyTTL(), limits)}
+	err = run(srv)
+	if cerr := db.Close(); cerr != nil {
+		fmt.Println("Store Close Error: ", cerr)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// Backend Config from Environment
+func storeConfig() store.Config {
+	return store.Config{
+		Backend: getenv("STORE", "memory"),
+		Serial:  getenv("SERIAL", "1234998871109"),
+		MySQL:   getenv("MYSQL", "root:cmpe281@tcp(mysql:3306)/cmpe281"),
+		Mongo:   getenv("MONGODB", "mongodb"),
+		Redis:   getenv("REDIS", "redis:6379"),
+		Riak:    getenv("RIAK", "http://riak:8098"),
+	}
+}
+
+// Inventory Locker from Environment
+// LOCK is redis, mysql, memory or none.  By default the redis and
+// mysql backends lock through their own server and the others lock
+// within this process.
+func inventoryLocker(cfg store.Config) (lock.Locker, error) {
+	kind := os.Getenv("LOCK")
+	if kind == "" {
+		kind = "memory"
+		if cfg.Backend == "redis" || cfg.Backend == "mysql" {
+			kind = cfg.Backend
+		}
+	}
+	switch kind {
+	case "none":
+		return nil, nil
+	case "memory":
+		return lock.NewMemoryLocker(), nil
+	case "redis":
+		return lock.OpenRedisLocker(cfg.Redis)
+	case "mysql":
+		return lock.OpenMySQLLocker(cfg.MySQL)
+	}
+	return nil, fmt.Errorf("unknown LOCK %q", kind)
+}
+
+// Inventory Lock Lease from Environment
+func lockTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("LOCK_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 5 * time.Second
+}
+
+// Rate Limits from Environment
+// RATE_LIMIT applies per client to every route and RATE_LIMIT_ORDERS
+// to placing orders, each as requests/window or off.
+// RATE_LIMIT_STORE is redis or memory, by default redis for the
+// redis backend.  With a Redis store, orders default to 10/1m per
+// client and RATE_LIMIT_ORDERS=off turns that off; otherwise both
+// limits default to off.  RATE_LIMIT_TRUST_PROXY=true limits by Kong
+// consumer or X-Forwarded-For when the API sits behind Kong;
+// without it every request from Kong shares one bucket.
+func rateLimits(cfg store.Config) (*ratelimit.Middleware, error) {
+	kind := os.Getenv("RATE_LIMIT_STORE")
+	if kind == "" {
+		kind = "memory"
+		if cfg.Backend == "redis" {
+			kind = "redis"
+		}
+	}
+	if kind != "memory" && kind != "redis" {
+		return nil, fmt.Errorf("unknown RATE_LIMIT_STORE %q", kind)
+	}
+
+	var rules []ratelimit.Rule
+	ordersDefault := "off"
+	if kind == "redis" {
+		ordersDefault = "10/1m"
+	}
+	orders, err := rateLimit("RATE_LIMIT_ORDERS", ordersDefault)
+	if err != nil {
+		return nil, err
+	}
+	if orders != nil {
+		rules = append(rules,
+			ratelimit.Rule{Name: "order", Method: "POST", Path: "/order", Limit: *orders},
+			ratelimit.Rule{Name: "order", Method: "POST", Path: "/gumball/*/order", Limit: *orders})
+	}
+	all, err := rateLimit("RATE_LIMIT", "off")
+	if err != nil {
+		return nil, err
+	}
+	if all != nil {
+		rules = append(rules, ratelimit.Rule{Name: "api", Limit: *all})
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var limiter ratelimit.Limiter
+	if kind == "redis" {
+		if limiter, err = ratelimit.OpenRedisLimiter(cfg.Redis); err != nil {
+			return nil, err
+		}
+	} else {
+		limiter = ratelimit.NewMemoryLimiter()
+	}
+	m := ratelimit.NewMiddleware(limiter, rules...)
+	m.TrustProxy = os.Getenv("RATE_LIMIT_TRUST_PROXY") == "true"
+	return m, nil
+}
+
+func rateLimit(key, value string) (*ratelimit.Limit, error) {
+	s := getenv(key, value)
+	if s == "off" {
+		return nil, nil
+	}
+	l, err := ratelimit.ParseLimit(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	return &l, nil
+}
+
+// Event Publisher from Environment
+// Events are only published when EVENTS names a RabbitMQ server.
+func eventPublisher() events.Publisher {
+	url := os.Getenv("EVENTS")
+	if url == "" {
+		return events.Discard{}
+	}
+	return events.NewAMQPPublisher(url, getenv("EVENTS_EXCHANGE", events.Exchange))
+}
+
+// Idempotency Key Lifetime from Environment
+func keyTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+func getenv(key, value string) string {
+	if v := os.Getenv(key); len(v) > 0 {
+		return v
+	}
+	return value
+}
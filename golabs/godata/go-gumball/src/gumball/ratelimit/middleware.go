@@ -0,0 +1,146 @@
+/*
+	Gumball API in Go
+	Rate Limit Middleware
+*/
+
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/unrolled/render"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	The middleware runs ahead of the router.  The first rule that
+	matches a request names the bucket it takes from; requests that
+	match no rule are not limited.
+
+	Clients are told their limit in the RateLimit headers of the
+	IETF draft, in whole seconds:
+
+		RateLimit-Policy	10;w=60
+		RateLimit-Limit		10
+		RateLimit-Remaining	3
+		RateLimit-Reset		42
+
+	and a refused request gets 429 Too Many Requests with
+	Retry-After.  If the limiter fails the request is let through.
+*/
+
+// Consumer Header Set by Kong
+// Kong's key-auth plugin sets it after checking the API key, and
+// replaces any value the client sent.
+var ConsumerHeader = "X-Consumer-ID"
+
+// Per-Route Limit
+// Path is matched by segment and * matches any one segment; an
+// empty Method or Path matches all.
+type Rule struct {
+	Name   string
+	Method string
+	Path   string
+	Limit  Limit
+}
+
+func (r Rule) matches(req *http.Request) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	want := strings.Split(strings.Trim(r.Path, "/"), "/")
+	got := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != "*" && want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Negroni Rate Limit Handler
+// Clients are told apart by their address.  Set TrustProxy only
+// behind Kong: clients are then told apart by the Kong consumer,
+// or else by the last X-Forwarded-For address, the one Kong
+// added.  Both headers can be forged by a client that reaches
+// the API directly.
+type Middleware struct {
+	limiter    Limiter
+	formatter  *render.Render
+	rules      []Rule
+	TrustProxy bool
+}
+
+func NewMiddleware(limiter Limiter, rules ...Rule) *Middleware {
+	formatter := render.New(render.Options{
+		IndentJSON: true,
+	})
+	return &Middleware{limiter: limiter, formatter: formatter, rules: rules}
+}
+
+func (m *Middleware) ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	rule, ok := m.match(req)
+	if !ok {
+		next(w, req)
+		return
+	}
+	res, err := m.limiter.Allow(rule.Name+":"+m.client(req), rule.Limit, time.Now())
+	if err != nil {
+		fmt.Println("Rate Limit Error: ", err)
+		next(w, req)
+		return
+	}
+	h := w.Header()
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit.Requests, seconds(rule.Limit.Window)))
+	h.Set("RateLimit-Limit", strconv.Itoa(rule.Limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.FormatInt(seconds(res.Reset), 10))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(seconds(res.RetryAfter), 10))
+		m.formatter.JSON(w, http.StatusTooManyRequests, struct{ Error string }{"Too Many Requests"})
+		return
+	}
+	next(w, req)
+}
+
+func (m *Middleware) match(req *http.Request) (Rule, bool) {
+	for _, r := range m.rules {
+		if r.matches(req) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Bucket Key for the Client
+func (m *Middleware) client(req *http.Request) string {
+	if m.TrustProxy {
+		if id := req.Header.Get(ConsumerHeader); id != "" {
+			return "consumer:" + id
+		}
+		if fwd := req.Header.Get("X-Forwarded-For"); fwd != "" {
+			addrs := strings.Split(fwd, ",")
+			return "ip:" + strings.TrimSpace(addrs[len(addrs)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Whole Seconds, Rounded Up
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
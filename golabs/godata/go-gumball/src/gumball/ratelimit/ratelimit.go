@@ -0,0 +1,159 @@
+/*
+	Gumball API in Go
+	Per-Client Rate Limits
+*/
+
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Each client gets a token bucket per rule.  A bucket holds up to
+	Limit.Requests tokens and refills at Requests per Window, so a
+	client may burst the whole limit and then continues at the
+	refill rate.  Every request takes a token; a request finding the
+	bucket empty is refused until the next token arrives.
+
+	Limiters:
+		MemoryLimiter	one process, for the memory backend and tests
+		RedisLimiter	Lua script over a hash per bucket, shared by
+				every replica
+*/
+
+var ErrInvalidLimit = errors.New("ratelimit: invalid limit")
+
+// Requests per Window
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit reads a limit written as requests/window, such as
+// 100/1m or 5/1s.
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, ErrInvalidLimit
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n < 1 {
+		return Limit{}, ErrInvalidLimit
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || d <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	return Limit{Requests: n, Window: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Window)
+}
+
+// Time for One Token to Refill
+func (l Limit) interval() time.Duration {
+	return l.Window / time.Duration(l.Requests)
+}
+
+// Outcome of a Request
+// Remaining is the whole tokens left, RetryAfter the wait for the
+// next token when refused and Reset the wait until the bucket is
+// full again.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Limiter
+//
+// Allow takes a token from the bucket named key at time now.
+type Limiter interface {
+	Allow(key string, limit Limit, now time.Time) (Result, error)
+	Close() error
+}
+
+// Token Bucket State
+type bucket struct {
+	Tokens float64
+	Time   time.Time
+	Full   time.Time
+}
+
+// Refill the Bucket up to now and Take a Token
+// A clock behind the bucket's is treated as no time passing.
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	interval := float64(limit.interval())
+	if now.After(b.Time) {
+		b.Tokens = math.Min(capacity, b.Tokens+float64(now.Sub(b.Time))/interval)
+		b.Time = now
+	}
+	res := Result{}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - b.Tokens) * interval))
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = time.Duration(math.Ceil((capacity - b.Tokens) * interval))
+	b.Full = b.Time.Add(res.Reset)
+	return res
+}
+
+// In-Process Limiter
+// Full buckets are dropped so idle clients do not accumulate.
+type MemoryLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket)}
+}
+
+func (l *MemoryLimiter) Allow(key string, limit Limit, now time.Time) (Result, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{Tokens: float64(limit.Requests), Time: now}
+		l.buckets[key] = b
+	}
+	res := b.take(limit, now)
+	l.sweep(now)
+	return res, nil
+}
+
+// Calls between Sweeps
+var sweep_every = 1000
+
+// Drop Buckets that have Refilled
+// A missing bucket starts full, so this changes no result.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	l.calls++
+	if l.calls < sweep_every {
+		return
+	}
+	l.calls = 0
+	for key, b := range l.buckets {
+		if !now.Before(b.Full) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *MemoryLimiter) Close() error {
+	return nil
+}
@@ -0,0 +1,156 @@
+package ratelimit_test
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"gumball/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	l, err := ratelimit.ParseLimit("10/1m")
+	if err != nil || l.Requests != 10 || l.Window != time.Minute {
+		t.Errorf("ParseLimit(10/1m) = %v, %v", l, err)
+	}
+	for _, s := range []string{"", "10", "0/1m", "x/1m", "10/x", "10/-1s"} {
+		if _, err := ratelimit.ParseLimit(s); err != ratelimit.ErrInvalidLimit {
+			t.Errorf("ParseLimit(%q) = %v, want ErrInvalidLimit", s, err)
+		}
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	testLimiter(t, ratelimit.NewMemoryLimiter())
+}
+
+func TestRedisLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	testLimiter(t, ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+}
+
+func testLimiter(t *testing.T, l ratelimit.Limiter) {
+	defer l.Close()
+	limit := ratelimit.Limit{Requests: 3, Window: 3 * time.Second}
+	now := time.Now()
+
+	for i := 2; i >= 0; i-- {
+		res, err := l.Allow("a", limit, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed || res.Remaining != i {
+			t.Errorf("Allow = %+v, want allowed with %d remaining", res, i)
+		}
+	}
+	res, err := l.Allow("a", limit, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("Allow(empty) = %+v, want refused for 1s resetting in 3s", res)
+	}
+	if res, _ := l.Allow("b", limit, now); !res.Allowed {
+		t.Errorf("Allow(other bucket) = %+v, want allowed", res)
+	}
+
+	// One token back after a second
+	if res, _ := l.Allow("a", limit, now.Add(time.Second)); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Allow(refilled) = %+v, want allowed with 0 remaining", res)
+	}
+	// A clock behind the bucket refills nothing
+	if res, _ := l.Allow("a", limit, now); res.Allowed {
+		t.Errorf("Allow(behind) = %+v, want refused", res)
+	}
+	// Never more than the limit
+	if res, _ := l.Allow("a", limit, now.Add(time.Hour)); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("Allow(idle) = %+v, want allowed with 2 remaining", res)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	orders := ratelimit.Rule{Name: "orders", Method: "POST", Path: "/gumball/*/order", Limit: ratelimit.Limit{Requests: 1, Window: time.Minute}}
+	all := ratelimit.Rule{Name: "all", Limit: ratelimit.Limit{Requests: 2, Window: time.Minute}}
+	m := ratelimit.NewMiddleware(ratelimit.NewMemoryLimiter(), orders, all)
+	ok := func(w http.ResponseWriter, req *http.Request) {}
+
+	serve := func(method, path, addr, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = addr
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+			req.Header.Set(ratelimit.ConsumerHeader, key)
+			req.Header.Set("X-Forwarded-For", key)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, req, ok)
+		return w
+	}
+
+	w := serve("POST", "/gumball/1/order", "10.0.0.1:5000", "")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("first order = %d %v", w.Code, w.Header())
+	}
+	w = serve("POST", "/gumball/2/order", "10.0.0.1:5001", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("second order = %d %v, want 429 retrying after 60", w.Code, w.Header())
+	}
+	if w := serve("POST", "/gumball/1/order", "10.0.0.2:5000", ""); w.Code != http.StatusOK {
+		t.Errorf("order from another address = %d", w.Code)
+	}
+	// Without a trusted proxy, client headers do not pick the bucket
+	if w := serve("POST", "/gumball/1/order", "10.0.0.1:5000", "secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("order with forged headers = %d, want 429", w.Code)
+	}
+
+	// Other routes take from their own bucket
+	for i := 0; i < 2; i++ {
+		if w := serve("GET", "/gumball/1", "10.0.0.1:5000", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("GET %d = %d %v", i, w.Code, w.Header())
+		}
+	}
+	if w := serve("GET", "/gumball", "10.0.0.1:5000", ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("third GET = %d, want 429", w.Code)
+	}
+}
+
+func TestMiddlewareBehindProxy(t *testing.T) {
+	orders := ratelimit.Rule{Name: "orders", Method: "POST", Path: "/order", Limit: ratelimit.Limit{Requests: 1, Window: time.Minute}}
+	m := ratelimit.NewMiddleware(ratelimit.NewMemoryLimiter(), orders)
+	m.TrustProxy = true
+	ok := func(w http.ResponseWriter, req *http.Request) {}
+
+	// Every request comes from Kong's address
+	serve := func(consumer, fwd string) int {
+		req := httptest.NewRequest("POST", "/order", nil)
+		req.RemoteAddr = "172.17.0.2:40000"
+		if consumer != "" {
+			req.Header.Set(ratelimit.ConsumerHeader, consumer)
+		}
+		if fwd != "" {
+			req.Header.Set("X-Forwarded-For", fwd)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, req, ok)
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		name, consumer, fwd string
+		code                int
+	}{
+		{"first consumer", "c1", "10.0.0.1", http.StatusOK},
+		{"same consumer, new address", "c1", "10.0.0.9", http.StatusTooManyRequests},
+		{"other consumer", "c2", "10.0.0.1", http.StatusOK},
+		{"anonymous client", "", "10.0.0.1", http.StatusOK},
+		// Kong appends the address it saw; a client can only prepend
+		{"forged first address", "", "10.9.9.9, 10.0.0.1", http.StatusTooManyRequests},
+		{"other address", "", "10.0.0.2", http.StatusOK},
+	} {
+		if code := serve(tc.consumer, tc.fwd); code != tc.code {
+			t.Errorf("%s = %d, want %d", tc.name, code, tc.code)
+		}
+	}
+}
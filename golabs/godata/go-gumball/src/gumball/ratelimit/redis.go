@@ -0,0 +1,99 @@
+/*
+	Gumball API in Go
+	Redis Rate Limits
+*/
+
+package ratelimit
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+/*
+	Keys:
+		gumball:ratelimit:{key}		hash of tokens and refill time in
+						milliseconds, expiring once full
+
+	The script refills and takes a token in one step, so replicas
+	sharing a bucket cannot both spend its last token.  Time comes
+	from the replica making the request; a replica whose clock is
+	behind the bucket's refills nothing until it catches up.
+*/
+
+// Refill and Take a Token
+// KEYS[1] bucket; ARGV capacity, ms per token, now in ms
+// Returns allowed, remaining, retry after ms, reset ms
+var redis_take = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'time')
+local tokens = tonumber(state[1]) or capacity
+local time = tonumber(state[2]) or now
+if now > time then
+	tokens = math.min(capacity, tokens + (now - time) / interval)
+	time = now
+end
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * interval)
+end
+local reset = math.ceil((capacity - tokens) * interval)
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'time', time)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+func OpenRedisLimiter(connect string) (*RedisLimiter, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     connect,
+		Password: "", // no password set
+		DB:       0,  // use default DB
+	})
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return NewRedisLimiter(client), nil
+}
+
+// NewRedisLimiter uses a connected client.
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+func (l *RedisLimiter) Allow(key string, limit Limit, now time.Time) (Result, error) {
+	keys := []string{"gumball:ratelimit:" + key}
+	interval := float64(limit.interval()) / float64(time.Millisecond)
+	res, err := redis_take.Run(l.client, keys, limit.Requests, interval, now.UnixNano()/int64(time.Millisecond)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	vals, _ := res.([]interface{})
+	if len(vals) != 4 {
+		return Result{}, errors.New("ratelimit: unexpected script reply")
+	}
+	n := make([]int64, 4)
+	for i, v := range vals {
+		n[i], _ = v.(int64)
+	}
+	return Result{
+		Allowed:    n[0] == 1,
+		Remaining:  int(n[1]),
+		RetryAfter: time.Duration(n[2]) * time.Millisecond,
+		Reset:      time.Duration(n[3]) * time.Millisecond,
+	}, nil
+}
+
+func (l *RedisLimiter) Close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,365 @@
+/*
+	Gumball API in Go
+	Pluggable Storage Backends
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/codegangsta/negroni"
+	"github.com/gorilla/mux"
+	"github.com/satori/go.uuid"
+	"github.com/unrolled/render"
+	"gumball/lock"
+	"gumball/machine"
+	"gumball/ratelimit"
+	"gumball/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewServer configures and returns a Server.  Orders posted to
+// /order are placed on the machine with the given serial number.
+// Idempotency keys are kept for keyTTL.  Requests pass through
+// limits first unless it is nil.
+func NewServer(db store.Store, serial string, keyTTL time.Duration, limits *ratelimit.Middleware) *negroni.Negroni {
+	formatter := render.New(render.Options{
+		IndentJSON: true,
+	})
+	n := negroni.Classic()
+	if limits != nil {
+		n.Use(limits)
+	}
+	mx := mux.NewRouter()
+	initRoutes(mx, formatter, db, serial, keyTTL)
+	initFleetRoutes(mx, formatter, db, keyTTL)
+	initMachineRoutes(mx, formatter, newMachineRegistry(db))
+	n.UseHandler(mx)
+	return n
+}
+
+// API Routes
+func initRoutes(mx *mux.Router, formatter *render.Render, db store.Store, serial string, keyTTL time.Duration) {
+	mx.HandleFunc("/ping", pingHandler(formatter)).Methods("GET")
+	mx.HandleFunc("/order", gumballNewOrderHandler(formatter, db, serial, keyTTL)).Methods("POST")
+	mx.HandleFunc("/order/{id}", gumballOrderStatusHandler(formatter, db)).Methods("GET")
+	mx.HandleFunc("/order", gumballOrderStatusHandler(formatter, db)).Methods("GET")
+	mx.HandleFunc("/order/{id}", gumballProcessOrderHandler(formatter, db)).Methods("POST")
+	mx.HandleFunc("/order/{id}", gumballCancelOrderHandler(formatter, db)).Methods("DELETE")
+	mx.HandleFunc("/orders", gumballProcessOrdersHandler(formatter, db)).Methods("POST")
+}
+
+// Fleet Routes
+func initFleetRoutes(mx *mux.Router, formatter *render.Render, db store.Store, keyTTL time.Duration) {
+	mx.HandleFunc("/gumball", gumballListHandler(formatter, db)).Methods("GET")
+	mx.HandleFunc("/gumball", gumballRegisterHandler(formatter, db)).Methods("POST")
+	mx.HandleFunc("/gumball/{serial}", gumballHandler(formatter, db)).Methods("GET")
+	mx.HandleFunc("/gumball/{serial}", gumballUpdateHandler(formatter, db)).Methods("PUT")
+	mx.HandleFunc("/gumball/{serial}", gumballRetireHandler(formatter, db)).Methods("DELETE")
+	mx.HandleFunc("/gumball/{serial}/order", gumballMachineOrderHandler(formatter, db, keyTTL)).Methods("POST")
+	mx.HandleFunc("/gumball/{serial}/order", gumballMachineOrdersHandler(formatter, db)).Methods("GET")
+}
+
+// Gumball Machine Routes
+func initMachineRoutes(mx *mux.Router, formatter *render.Render, machines *machineRegistry) {
+	mx.HandleFunc("/gumball/{serial}/state", machineActionHandler(formatter, machines, (*machine.GumballMachine).Status)).Methods("GET")
+	mx.HandleFunc("/gumball/{serial}/quarter", machineActionHandler(formatter, machines, (*machine.GumballMachine).InsertQuarter)).Methods("POST")
+	mx.HandleFunc("/gumball/{serial}/eject", machineActionHandler(formatter, machines, (*machine.GumballMachine).EjectQuarter)).Methods("POST")
+	mx.HandleFunc("/gumball/{serial}/crank", machineActionHandler(formatter, machines, (*machine.GumballMachine).TurnCrank)).Methods("POST")
+}
+
+// API Error Response
+func errorResponse(formatter *render.Render, w http.ResponseWriter, err error) {
+	fmt.Println("Error: ", err)
+	switch err {
+	case store.ErrNotFound:
+		formatter.JSON(w, http.StatusNotFound, struct{ Error string }{"Not Found"})
+	case store.ErrOutOfStock:
+		formatter.JSON(w, http.StatusConflict, struct{ Error string }{"Out of Stock"})
+	case store.ErrInvalidTransition:
+		formatter.JSON(w, http.StatusConflict, struct{ Error string }{"Invalid Order Transition"})
+	case store.ErrInvalidInventory:
+		formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+	case store.ErrExists:
+		formatter.JSON(w, http.StatusConflict, struct{ Error string }{"Machine Already Registered"})
+	case store.ErrVersionMismatch:
+		formatter.JSON(w, http.StatusPreconditionFailed, struct{ Error string }{"Gumball Machine Changed"})
+	case lock.ErrHeld, store.ErrStaleToken:
+		formatter.JSON(w, http.StatusServiceUnavailable, struct{ Error string }{"Gumball Machine Busy"})
+	default:
+		formatter.JSON(w, http.StatusInternalServerError, struct{ Error string }{"Storage Error"})
+	}
+}
+
+// API Ping Handler
+func pingHandler(formatter *render.Render) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		formatter.JSON(w, http.StatusOK, struct{ Test string }{"API version 1.0 alive!"})
+	}
+}
+
+// API List Gumball Machines
+func gumballListHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		machines_array, err := db.ListMachines()
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		formatter.JSON(w, http.StatusOK, machines_array)
+	}
+}
+
+// API Register Gumball Machine
+func gumballRegisterHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		var m store.Machine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil || m.SerialNumber == "" {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Machine"})
+			return
+		}
+		m, err := db.RegisterMachine(m)
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Registered Gumball Machine: ", m)
+		formatter.JSON(w, http.StatusOK, m)
+	}
+}
+
+// API Gumball Machine Handler
+func gumballHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		m, err := db.GetMachine(params["serial"])
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Gumball Machine:", m)
+		w.Header().Set("ETag", versionTag(m.Version))
+		formatter.JSON(w, http.StatusOK, m)
+	}
+}
+
+// API Update Gumball Inventory
+// The If-Match header must carry the ETag from GET /gumball/{serial}.
+func gumballUpdateHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		header := req.Header.Get("If-Match")
+		if header == "" {
+			formatter.JSON(w, http.StatusPreconditionRequired, struct{ Error string }{"If-Match Required"})
+			return
+		}
+		version, ok := matchVersion(header)
+		if !ok {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid If-Match"})
+			return
+		}
+		var m store.Machine
+		if err := json.NewDecoder(req.Body).Decode(&m); err != nil || m.CountGumballs < 0 {
+			formatter.JSON(w, http.StatusBadRequest, struct{ Error string }{"Invalid Inventory"})
+			return
+		}
+		fmt.Println("Update Gumball Inventory To: ", m.CountGumballs)
+		m, err := db.UpdateInventoryIf(params["serial"], version, m.CountGumballs)
+		if err == store.ErrVersionMismatch {
+			w.Header().Set("ETag", versionTag(m.Version))
+		}
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		w.Header().Set("ETag", versionTag(m.Version))
+		formatter.JSON(w, http.StatusOK, m)
+	}
+}
+
+// Machine Version as a Strong ETag
+func versionTag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Version named by an If-Match header
+func matchVersion(header string) (int64, bool) {
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	return version, err == nil
+}
+
+// API Retire Gumball Machine
+func gumballRetireHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		if err := db.RetireMachine(params["serial"]); err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Retired Gumball Machine: ", params["serial"])
+		formatter.JSON(w, http.StatusOK, "Machine Retired!")
+	}
+}
+
+// API Create Order on a Gumball Machine
+func gumballMachineOrderHandler(formatter *render.Render, db store.Store, keyTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		placeOrder(formatter, w, req, db, params["serial"], keyTTL)
+	}
+}
+
+// API List Orders of a Gumball Machine
+func gumballMachineOrdersHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		orders_array, err := db.ListOrders()
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		var result = []store.Order{}
+		for _, ord := range orders_array {
+			if ord.SerialNumber == params["serial"] {
+				result = append(result, ord)
+			}
+		}
+		formatter.JSON(w, http.StatusOK, result)
+	}
+}
+
+// API Gumball Machine Action
+func machineActionHandler(formatter *render.Render, machines *machineRegistry, action func(*machine.GumballMachine) (machine.Result, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		gm, err := machines.lookup(params["serial"])
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		result, err := action(gm)
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Gumball Machine: ", result)
+		formatter.JSON(w, http.StatusOK, result)
+	}
+}
+
+// API Create New Gumball Order
+func gumballNewOrderHandler(formatter *render.Render, db store.Store, serial string, keyTTL time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		placeOrder(formatter, w, req, db, serial, keyTTL)
+	}
+}
+
+// A request carrying an Idempotency-Key places at most one order
+// per key; see idempotency.go.
+func placeOrder(formatter *render.Render, w http.ResponseWriter, req *http.Request, db store.Store, serial string, keyTTL time.Duration) {
+	uuid := uuid.NewV4()
+	key := req.Header.Get("Idempotency-Key")
+	if key != "" && !claimOrderKey(formatter, w, req, db, serial, key, uuid.String(), keyTTL) {
+		return
+	}
+	ord, err := db.CreateOrder(store.Order{
+		Id:           uuid.String(),
+		SerialNumber: serial,
+		OrderStatus:  store.OrderPlaced,
+	})
+	if err != nil {
+		if key != "" {
+			db.ReleaseKey(key)
+		}
+		errorResponse(formatter, w, err)
+		return
+	}
+	fmt.Println("Order: ", ord)
+	formatter.JSON(w, http.StatusOK, ord)
+}
+
+// API Get Order Status
+func gumballOrderStatusHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		var uuid string = params["id"]
+		fmt.Println("Order ID: ", uuid)
+		if uuid == "" {
+			orders_array, err := db.ListOrders()
+			if err != nil {
+				errorResponse(formatter, w, err)
+				return
+			}
+			formatter.JSON(w, http.StatusOK, orders_array)
+		} else {
+			ord, err := db.GetOrder(uuid)
+			if err != nil {
+				errorResponse(formatter, w, err)
+				return
+			}
+			fmt.Println("Order: ", ord)
+			formatter.JSON(w, http.StatusOK, ord)
+		}
+	}
+}
+
+// API Process Order
+func gumballProcessOrderHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		ord, err := db.ProcessOrder(params["id"])
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Processed Order: ", ord)
+		formatter.JSON(w, http.StatusOK, ord)
+	}
+}
+
+// API Cancel Order
+func gumballCancelOrderHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := mux.Vars(req)
+		ord, err := db.CancelOrder(params["id"])
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		fmt.Println("Cancelled Order: ", ord)
+		formatter.JSON(w, http.StatusOK, ord)
+	}
+}
+
+// API Process Orders
+// Orders that cannot be filled are marked failed and skipped,
+// as are orders on retired machines.
+func gumballProcessOrdersHandler(formatter *render.Render, db store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		orders_array, err := db.ListOrders()
+		if err != nil {
+			errorResponse(formatter, w, err)
+			return
+		}
+		for _, ord := range orders_array {
+			if ord.OrderStatus != store.OrderPlaced {
+				continue
+			}
+			_, err := db.ProcessOrder(ord.Id)
+			if err == store.ErrOutOfStock || err == store.ErrInvalidTransition || err == store.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				errorResponse(formatter, w, err)
+				return
+			}
+		}
+		formatter.JSON(w, http.StatusOK, "Orders Processed!")
+	}
+}
@@ -0,0 +1,40 @@
+/*
+	Gumball API in Go
+	Idempotency Keys
+*/
+
+package store
+
+import "time"
+
+// Idempotency Key
+// Remembers the request a key was first used with and the order
+// that request created.  ClaimedAt and Expires are in Unix
+// milliseconds and are set by ClaimKey; a key stored before
+// ClaimedAt existed reads as claimed at 0.
+type IdempotencyKey struct {
+	Key         string `bson:"Key"`
+	Fingerprint string `bson:"Fingerprint"`
+	OrderId     string `bson:"OrderId"`
+	ClaimedAt   int64  `bson:"ClaimedAt"`
+	Expires     int64  `bson:"Expires"`
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (k IdempotencyKey) expired() bool {
+	return k.Expires <= nowMillis()
+}
+
+func (k IdempotencyKey) withTTL(ttl time.Duration) IdempotencyKey {
+	k.ClaimedAt = nowMillis()
+	k.Expires = k.ClaimedAt + int64(ttl/time.Millisecond)
+	return k
+}
+
+// ClaimedBefore reports whether the key was claimed before t.
+func (k IdempotencyKey) ClaimedBefore(t time.Time) bool {
+	return k.ClaimedAt < t.UnixNano()/int64(time.Millisecond)
+}
@@ -0,0 +1,250 @@
+/*
+	Gumball API in Go
+	In-Memory Backend
+*/
+
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mutex    sync.Mutex
+	machines map[string]Machine
+	orders   map[string]Order
+	keys     map[string]IdempotencyKey
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		machines: make(map[string]Machine),
+		orders:   make(map[string]Order),
+		keys:     make(map[string]IdempotencyKey),
+	}
+}
+
+func (s *MemoryStore) ListMachines() ([]Machine, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var machines_array []Machine
+	for _, value := range s.machines {
+		machines_array = append(machines_array, value)
+	}
+	return machines_array, nil
+}
+
+func (s *MemoryStore) GetMachine(serial string) (Machine, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.machines[serial]
+	if !ok {
+		return Machine{}, ErrNotFound
+	}
+	return m, nil
+}
+
+func (s *MemoryStore) RegisterMachine(m Machine) (Machine, error) {
+	if m.CountGumballs < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.machines[m.SerialNumber]; ok {
+		return Machine{}, ErrExists
+	}
+	m.Version = 0
+	s.machines[m.SerialNumber] = m
+	return m, nil
+}
+
+func (s *MemoryStore) RetireMachine(serial string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.machines[serial]; !ok {
+		return ErrNotFound
+	}
+	delete(s.machines, serial)
+	return nil
+}
+
+func (s *MemoryStore) UpdateInventory(serial string, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.machines[serial]
+	if !ok {
+		return Machine{}, ErrNotFound
+	}
+	m.CountGumballs = count
+	m.Version++
+	s.machines[serial] = m
+	return m, nil
+}
+
+func (s *MemoryStore) UpdateInventoryIf(serial string, version int64, count int) (Machine, error) {
+	return s.updateInventoryIf(serial, 0, version, count)
+}
+
+func (s *MemoryStore) UpdateInventoryFenced(serial string, token, version int64, count int) (Machine, error) {
+	if token < 1 {
+		return Machine{}, ErrStaleToken
+	}
+	return s.updateInventoryIf(serial, token, version, count)
+}
+
+func (s *MemoryStore) updateInventoryIf(serial string, token, version int64, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, ok := s.machines[serial]
+	if !ok {
+		return Machine{}, ErrNotFound
+	}
+	current := m
+	if err := m.fence(token); err != nil {
+		return current, err
+	}
+	if m.Version != version {
+		return current, ErrVersionMismatch
+	}
+	m.CountGumballs = count
+	m.Version++
+	s.machines[serial] = m
+	return m, nil
+}
+
+func (s *MemoryStore) CreateOrder(ord Order) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.machines[ord.SerialNumber]; !ok {
+		return Order{}, ErrNotFound
+	}
+	s.orders[ord.Id] = ord
+	return ord, nil
+}
+
+func (s *MemoryStore) GetOrder(id string) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ord, ok := s.orders[id]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	return ord, nil
+}
+
+func (s *MemoryStore) ListOrders() ([]Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var orders_array []Order
+	for _, value := range s.orders {
+		orders_array = append(orders_array, value)
+	}
+	return orders_array, nil
+}
+
+func (s *MemoryStore) ProcessOrder(id string) (Order, error) {
+	return s.processOrder(id, 0)
+}
+
+func (s *MemoryStore) ProcessOrderFenced(id string, token int64) (Order, error) {
+	if token < 1 {
+		return Order{}, ErrStaleToken
+	}
+	return s.processOrder(id, token)
+}
+
+func (s *MemoryStore) processOrder(id string, token int64) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ord, ok := s.orders[id]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	if !ord.OrderStatus.CanTransition(OrderProcessing) {
+		return ord, ErrInvalidTransition
+	}
+	m, ok := s.machines[ord.SerialNumber]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	if err := m.fence(token); err != nil {
+		return ord, err
+	}
+	if m.CountGumballs < 1 {
+		ord.OrderStatus = OrderFailed
+		ord.Reason = ReasonOutOfStock
+		s.orders[id] = ord
+		return ord, ErrOutOfStock
+	}
+	m.CountGumballs -= 1
+	m.Version++
+	s.machines[ord.SerialNumber] = m
+	ord.OrderStatus = OrderDispensed
+	s.orders[id] = ord
+	return ord, nil
+}
+
+func (s *MemoryStore) CancelOrder(id string) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ord, ok := s.orders[id]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	if !ord.OrderStatus.CanTransition(OrderCancelled) {
+		return ord, ErrInvalidTransition
+	}
+	ord.OrderStatus = OrderCancelled
+	s.orders[id] = ord
+	return ord, nil
+}
+
+// Expired keys are swept on each claim.
+func (s *MemoryStore) ClaimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.claimKey(k, ttl)
+}
+
+func (s *MemoryStore) claimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	for key, value := range s.keys {
+		if value.expired() {
+			delete(s.keys, key)
+		}
+	}
+	if old, ok := s.keys[k.Key]; ok {
+		return old, ErrExists
+	}
+	k = k.withTTL(ttl)
+	s.keys[k.Key] = k
+	return k, nil
+}
+
+func (s *MemoryStore) ReclaimKey(old, k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if cur, ok := s.keys[k.Key]; ok && cur.OrderId == old.OrderId {
+		k = k.withTTL(ttl)
+		s.keys[k.Key] = k
+		return k, nil
+	}
+	return s.claimKey(k, ttl)
+}
+
+func (s *MemoryStore) ReleaseKey(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.keys, key)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
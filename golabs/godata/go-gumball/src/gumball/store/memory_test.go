@@ -0,0 +1,13 @@
+package store_test
+
+import (
+	"gumball/store"
+	"gumball/store/storetest"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore()
+	})
+}
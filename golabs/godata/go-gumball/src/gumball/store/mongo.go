@@ -0,0 +1,461 @@
+/*
+	Gumball API in Go
+	MongoDB Backend
+*/
+
+package store
+
+import (
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+// MongoDB Config
+var mongodb_database = "cmpe281"
+var mongodb_collection = "gumball"
+var mongodb_orders = "orders"
+var mongodb_idempotency = "idempotency"
+
+// MongoDatabase is the part of MongoDB used by MongoStore.
+// Update and Remove return mgo.ErrNotFound when nothing matches;
+// UpdateAll changes every matching document, if any, and Upsert
+// reports whether it inserted a new document.  Writes that
+// break a unique index fail with an error mgo.IsDup accepts.
+type MongoDatabase interface {
+	C(name string) MongoCollection
+	Close()
+}
+
+type MongoCollection interface {
+	FindOne(query bson.M, result interface{}) error
+	FindAll(query bson.M, result interface{}) error
+	Insert(doc interface{}) error
+	Update(selector, change bson.M) error
+	UpdateAll(selector, change bson.M) error
+	Upsert(selector, change bson.M) (bool, error)
+	Remove(selector bson.M) error
+	EnsureIndex(index mgo.Index) error
+}
+
+type MongoStore struct {
+	db MongoDatabase
+}
+
+func OpenMongoStore(server string) (*MongoStore, error) {
+	session, err := mgo.Dial(server)
+	if err != nil {
+		return nil, err
+	}
+	session.SetMode(mgo.Monotonic, true)
+	s, err := NewMongoStore(&mgoDatabase{session: session, name: mongodb_database})
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewMongoStore uses an open database, creates its indexes and
+// fills in machine fields that older documents lack.
+func NewMongoStore(db MongoDatabase) (*MongoStore, error) {
+	if err := ensureMongoIndexes(db); err != nil {
+		return nil, err
+	}
+	if err := addMachineFields(db); err != nil {
+		return nil, err
+	}
+	return &MongoStore{db: db}, nil
+}
+
+// Machines stored before Version and Fence existed, or seeded by
+// hand, have neither field, and the conditional updates match on
+// both.  Missing fields are set to 0.
+func addMachineFields(db MongoDatabase) error {
+	machines := db.C(mongodb_collection)
+	for _, field := range []string{"Version", "Fence"} {
+		err := machines.UpdateAll(
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field: int64(0)}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unique indexes make concurrent upserts on the same key
+// insert once; the loser gets a duplicate key error.  MongoDB
+// removes idempotency keys once ExpireAt has passed; the TTL
+// monitor runs about once a minute, so ClaimKey still removes
+// an expired key before claiming it again.
+func ensureMongoIndexes(db MongoDatabase) error {
+	indexes := map[string][]mgo.Index{
+		mongodb_collection: {{Key: []string{"SerialNumber"}, Unique: true}},
+		mongodb_orders:     {{Key: []string{"Id"}, Unique: true}},
+		mongodb_idempotency: {
+			{Key: []string{"Key"}, Unique: true},
+			{Key: []string{"ExpireAt"}, ExpireAfter: time.Second},
+		},
+	}
+	for name, list := range indexes {
+		for _, index := range list {
+			if err := db.C(name).EnsureIndex(index); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MongoStore) ListMachines() ([]Machine, error) {
+	var machines_array []Machine
+	err := s.db.C(mongodb_collection).FindAll(nil, &machines_array)
+	return machines_array, err
+}
+
+func (s *MongoStore) GetMachine(serial string) (Machine, error) {
+	var m Machine
+	err := s.db.C(mongodb_collection).FindOne(bson.M{"SerialNumber": serial}, &m)
+	if err == mgo.ErrNotFound {
+		return Machine{}, ErrNotFound
+	}
+	return m, err
+}
+
+func (s *MongoStore) RegisterMachine(m Machine) (Machine, error) {
+	if m.CountGumballs < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	m.Version = 0
+	inserted, err := s.db.C(mongodb_collection).Upsert(
+		bson.M{"SerialNumber": m.SerialNumber},
+		bson.M{"$setOnInsert": m})
+	if mgo.IsDup(err) {
+		return Machine{}, ErrExists
+	}
+	if err != nil {
+		return Machine{}, err
+	}
+	if !inserted {
+		return Machine{}, ErrExists
+	}
+	return m, nil
+}
+
+func (s *MongoStore) RetireMachine(serial string) error {
+	err := s.db.C(mongodb_collection).Remove(bson.M{"SerialNumber": serial})
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *MongoStore) UpdateInventory(serial string, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	query := bson.M{"SerialNumber": serial}
+	change := bson.M{"$set": bson.M{"CountGumballs": count}, "$inc": bson.M{"Version": 1}}
+	err := s.db.C(mongodb_collection).Update(query, change)
+	if err == mgo.ErrNotFound {
+		return Machine{}, ErrNotFound
+	}
+	if err != nil {
+		return Machine{}, err
+	}
+	return s.GetMachine(serial)
+}
+
+func (s *MongoStore) UpdateInventoryIf(serial string, version int64, count int) (Machine, error) {
+	return s.updateInventoryIf(serial, 0, version, count)
+}
+
+func (s *MongoStore) UpdateInventoryFenced(serial string, token, version int64, count int) (Machine, error) {
+	if token < 1 {
+		return Machine{}, ErrStaleToken
+	}
+	return s.updateInventoryIf(serial, token, version, count)
+}
+
+func (s *MongoStore) updateInventoryIf(serial string, token, version int64, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	query := bson.M{"SerialNumber": serial, "Version": version}
+	set := bson.M{"CountGumballs": count}
+	fenceChange(query, set, token)
+	change := bson.M{"$set": set, "$inc": bson.M{"Version": 1}}
+	err := s.db.C(mongodb_collection).Update(query, change)
+	if err == mgo.ErrNotFound {
+		m, err := s.GetMachine(serial)
+		if err != nil {
+			return Machine{}, err
+		}
+		if token > 0 && m.Fence > token {
+			return m, ErrStaleToken
+		}
+		return m, ErrVersionMismatch
+	}
+	if err != nil {
+		return Machine{}, err
+	}
+	return s.GetMachine(serial)
+}
+
+func (s *MongoStore) CreateOrder(ord Order) (Order, error) {
+	if _, err := s.GetMachine(ord.SerialNumber); err != nil {
+		return Order{}, err
+	}
+	if err := s.db.C(mongodb_orders).Insert(ord); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *MongoStore) GetOrder(id string) (Order, error) {
+	var ord Order
+	err := s.db.C(mongodb_orders).FindOne(bson.M{"Id": id}, &ord)
+	if err == mgo.ErrNotFound {
+		return Order{}, ErrNotFound
+	}
+	return ord, err
+}
+
+func (s *MongoStore) ListOrders() ([]Order, error) {
+	var orders_array []Order
+	err := s.db.C(mongodb_orders).FindAll(nil, &orders_array)
+	return orders_array, err
+}
+
+func (s *MongoStore) ProcessOrder(id string) (Order, error) {
+	return s.processOrder(id, 0)
+}
+
+func (s *MongoStore) ProcessOrderFenced(id string, token int64) (Order, error) {
+	if token < 1 {
+		return Order{}, ErrStaleToken
+	}
+	return s.processOrder(id, token)
+}
+
+func (s *MongoStore) processOrder(id string, token int64) (Order, error) {
+	orders := s.db.C(mongodb_orders)
+	machines := s.db.C(mongodb_collection)
+
+	ord, err := s.GetOrder(id)
+	if err != nil {
+		return Order{}, err
+	}
+
+	// Claim the order by moving it to processing
+	if err := s.transition(id, OrderPlaced, OrderProcessing); err != nil {
+		return Order{}, err
+	}
+	unclaim := func() {
+		orders.Update(bson.M{"Id": id}, bson.M{"$set": bson.M{"OrderStatus": OrderPlaced}})
+	}
+
+	// Take a gumball only while one is left
+	query := bson.M{"SerialNumber": ord.SerialNumber, "CountGumballs": bson.M{"$gte": 1}}
+	change := bson.M{"$inc": bson.M{"CountGumballs": -1, "Version": 1}}
+	if token > 0 {
+		set := bson.M{}
+		fenceChange(query, set, token)
+		change["$set"] = set
+	}
+	err = machines.Update(query, change)
+	if err == mgo.ErrNotFound {
+		// Empty, retired since the order was placed, or fenced off
+		m, err := s.GetMachine(ord.SerialNumber)
+		if err != nil {
+			unclaim()
+			return Order{}, err
+		}
+		if token > 0 && m.Fence > token {
+			unclaim()
+			return ord, ErrStaleToken
+		}
+		ord.OrderStatus, ord.Reason = OrderFailed, ReasonOutOfStock
+		err = orders.Update(bson.M{"Id": id}, bson.M{"$set": ord})
+		if err != nil {
+			return Order{}, err
+		}
+		return ord, ErrOutOfStock
+	}
+	if err != nil {
+		unclaim()
+		return Order{}, err
+	}
+	ord.OrderStatus = OrderDispensed
+	if err := orders.Update(bson.M{"Id": id}, bson.M{"$set": ord}); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *MongoStore) CancelOrder(id string) (Order, error) {
+	if err := s.transition(id, OrderPlaced, OrderCancelled); err != nil {
+		return Order{}, err
+	}
+	return s.GetOrder(id)
+}
+
+// Only Match a Machine whose Fence is at most token, and Record it
+func fenceChange(query, set bson.M, token int64) {
+	if token > 0 {
+		query["Fence"] = bson.M{"$lte": token}
+		set["Fence"] = token
+	}
+}
+
+// Conditional Status Change
+// Returns ErrInvalidTransition if the order is not in state from.
+func (s *MongoStore) transition(id string, from, to OrderStatus) error {
+	err := s.db.C(mongodb_orders).Update(
+		bson.M{"Id": id, "OrderStatus": from},
+		bson.M{"$set": bson.M{"OrderStatus": to}})
+	if err != mgo.ErrNotFound {
+		return err
+	}
+	if _, err := s.GetOrder(id); err != nil {
+		return err
+	}
+	return ErrInvalidTransition
+}
+
+// Idempotency Key Document
+// ExpireAt is the date the TTL index expires the key on.
+type mongoKey struct {
+	IdempotencyKey `bson:",inline"`
+	ExpireAt       time.Time `bson:"ExpireAt"`
+}
+
+// An expired key is removed before the key is claimed again.
+// A concurrent claim that loses the insert reads the winner's key.
+func (s *MongoStore) ClaimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	keys := s.db.C(mongodb_idempotency)
+	err := keys.Remove(bson.M{"Key": k.Key, "Expires": bson.M{"$lte": nowMillis()}})
+	if err != nil && err != mgo.ErrNotFound {
+		return IdempotencyKey{}, err
+	}
+	k = k.withTTL(ttl)
+	doc := mongoKey{IdempotencyKey: k, ExpireAt: time.Unix(0, k.Expires*int64(time.Millisecond))}
+	inserted, err := keys.Upsert(bson.M{"Key": k.Key}, bson.M{"$setOnInsert": doc})
+	if err != nil && !mgo.IsDup(err) {
+		return IdempotencyKey{}, err
+	}
+	if inserted {
+		return k, nil
+	}
+	var old IdempotencyKey
+	if err := keys.FindOne(bson.M{"Key": k.Key}, &old); err != nil {
+		return IdempotencyKey{}, err
+	}
+	return old, ErrExists
+}
+
+func (s *MongoStore) ReclaimKey(old, k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	k = k.withTTL(ttl)
+	doc := mongoKey{IdempotencyKey: k, ExpireAt: time.Unix(0, k.Expires*int64(time.Millisecond))}
+	err := s.db.C(mongodb_idempotency).Update(bson.M{"Key": k.Key, "OrderId": old.OrderId}, bson.M{"$set": doc})
+	if err == mgo.ErrNotFound {
+		return s.ClaimKey(k, ttl)
+	}
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	return k, nil
+}
+
+func (s *MongoStore) ReleaseKey(key string) error {
+	err := s.db.C(mongodb_idempotency).Remove(bson.M{"Key": key})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *MongoStore) Close() error {
+	s.db.Close()
+	return nil
+}
+
+// MongoDatabase on an mgo Session
+// Each operation runs on its own copy of the session.
+type mgoDatabase struct {
+	session *mgo.Session
+	name    string
+}
+
+type mgoCollection struct {
+	session *mgo.Session
+	db      string
+	name    string
+}
+
+func (d *mgoDatabase) C(name string) MongoCollection {
+	return &mgoCollection{session: d.session, db: d.name, name: name}
+}
+
+func (d *mgoDatabase) Close() {
+	d.session.Close()
+}
+
+func (c *mgoCollection) open() (*mgo.Session, *mgo.Collection) {
+	session := c.session.Copy()
+	return session, session.DB(c.db).C(c.name)
+}
+
+func (c *mgoCollection) FindOne(query bson.M, result interface{}) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Find(query).One(result)
+}
+
+func (c *mgoCollection) FindAll(query bson.M, result interface{}) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Find(query).All(result)
+}
+
+func (c *mgoCollection) Insert(doc interface{}) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Insert(doc)
+}
+
+func (c *mgoCollection) Update(selector, change bson.M) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Update(selector, change)
+}
+
+func (c *mgoCollection) UpdateAll(selector, change bson.M) error {
+	session, coll := c.open()
+	defer session.Close()
+	_, err := coll.UpdateAll(selector, change)
+	return err
+}
+
+func (c *mgoCollection) Upsert(selector, change bson.M) (bool, error) {
+	session, coll := c.open()
+	defer session.Close()
+	info, err := coll.Upsert(selector, change)
+	if err != nil {
+		return false, err
+	}
+	return info.UpsertedId != nil, nil
+}
+
+func (c *mgoCollection) Remove(selector bson.M) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.Remove(selector)
+}
+
+func (c *mgoCollection) EnsureIndex(index mgo.Index) error {
+	session, coll := c.open()
+	defer session.Close()
+	return coll.EnsureIndex(index)
+}
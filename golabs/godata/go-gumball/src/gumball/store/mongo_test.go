@@ -0,0 +1,357 @@
+package store_test
+
+import (
+	"fmt"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+	"gumball/store"
+	"gumball/store/storetest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMongoStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := store.NewMongoStore(newFakeMongo())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestMongoUniqueIndexes(t *testing.T) {
+	db := newFakeMongo()
+	if _, err := store.NewMongoStore(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ name, key string }{
+		{"gumball", "SerialNumber"},
+		{"orders", "Id"},
+		{"idempotency", "Key"},
+	} {
+		coll := db.C(c.name)
+		if err := coll.Insert(bson.M{c.key: "dup"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := coll.Insert(bson.M{c.key: "dup"}); !mgo.IsDup(err) {
+			t.Errorf("%s: second %s insert = %v, want duplicate key", c.name, c.key, err)
+		}
+	}
+
+	var ttl bool
+	for _, index := range db.indexes["idempotency"] {
+		if len(index.Key) == 1 && index.Key[0] == "ExpireAt" && index.ExpireAfter > 0 {
+			ttl = true
+		}
+	}
+	if !ttl {
+		t.Error("no TTL index on idempotency ExpireAt")
+	}
+}
+
+// A machine document written before Version and Fence existed
+func TestMongoLegacyMachine(t *testing.T) {
+	db := newFakeMongo()
+	legacy := bson.M{"SerialNumber": "1", "CountGumballs": 1, "ModelNumber": "M102988"}
+	if err := db.C("gumball").Insert(legacy); err != nil {
+		t.Fatal(err)
+	}
+	s, err := store.NewMongoStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := s.UpdateInventoryIf("1", 0, 2)
+	if err != nil {
+		t.Fatalf("UpdateInventoryIf(legacy, 0) = %v", err)
+	}
+	if m.CountGumballs != 2 || m.Version != 1 {
+		t.Errorf("machine after UpdateInventoryIf = %+v", m)
+	}
+	if _, err := s.CreateOrder(store.Order{Id: "a", SerialNumber: "1", OrderStatus: store.OrderPlaced}); err != nil {
+		t.Fatal(err)
+	}
+	ord, err := s.ProcessOrderFenced("a", 1)
+	if err != nil {
+		t.Fatalf("ProcessOrderFenced(legacy) = %v", err)
+	}
+	if ord.OrderStatus != store.OrderDispensed {
+		t.Errorf("order status = %q, want %q", ord.OrderStatus, store.OrderDispensed)
+	}
+	if m, _ := s.GetMachine("1"); m.CountGumballs != 1 || m.Fence != 1 {
+		t.Errorf("machine after ProcessOrderFenced = %+v", m)
+	}
+}
+
+// MongoDB Stand-In
+// Documents are kept as bson.M.  Queries support equality,
+// $exists and $gt/$gte/$lt/$lte; updates support $set, $inc and $setOnInsert.
+// Remove deletes the first matching document.  Unique indexes are
+// enforced on insert.
+type fakeMongo struct {
+	mutex       sync.Mutex
+	collections map[string][]bson.M
+	indexes     map[string][]mgo.Index
+}
+
+type fakeCollection struct {
+	db   *fakeMongo
+	name string
+}
+
+func newFakeMongo() *fakeMongo {
+	return &fakeMongo{collections: make(map[string][]bson.M), indexes: make(map[string][]mgo.Index)}
+}
+
+func (d *fakeMongo) C(name string) store.MongoCollection {
+	return &fakeCollection{db: d, name: name}
+}
+
+func (d *fakeMongo) Close() {}
+
+func (c *fakeCollection) FindOne(query bson.M, result interface{}) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, query) {
+			return convertDoc(doc, result)
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func (c *fakeCollection) FindAll(query bson.M, result interface{}) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	slice := reflect.ValueOf(result).Elem()
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+	for _, doc := range c.db.collections[c.name] {
+		if !matchDoc(doc, query) {
+			continue
+		}
+		elem := reflect.New(slice.Type().Elem())
+		if err := convertDoc(doc, elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+	return nil
+}
+
+func (c *fakeCollection) Insert(doc interface{}) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	var m bson.M
+	if err := convertDoc(doc, &m); err != nil {
+		return err
+	}
+	if err := c.checkUnique(m); err != nil {
+		return err
+	}
+	c.db.collections[c.name] = append(c.db.collections[c.name], m)
+	return nil
+}
+
+func (c *fakeCollection) Update(selector, change bson.M) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, selector) {
+			return applyChange(doc, change, false)
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func (c *fakeCollection) UpdateAll(selector, change bson.M) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, selector) {
+			if err := applyChange(doc, change, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeCollection) Upsert(selector, change bson.M) (bool, error) {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	for _, doc := range c.db.collections[c.name] {
+		if matchDoc(doc, selector) {
+			return false, applyChange(doc, change, false)
+		}
+	}
+	doc := bson.M{}
+	for key, value := range selector {
+		if _, ok := value.(bson.M); !ok {
+			doc[key] = value
+		}
+	}
+	if err := applyChange(doc, change, true); err != nil {
+		return false, err
+	}
+	if err := c.checkUnique(doc); err != nil {
+		return false, err
+	}
+	c.db.collections[c.name] = append(c.db.collections[c.name], doc)
+	return true, nil
+}
+
+func (c *fakeCollection) Remove(selector bson.M) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	docs := c.db.collections[c.name]
+	for i, doc := range docs {
+		if matchDoc(doc, selector) {
+			c.db.collections[c.name] = append(docs[:i:i], docs[i+1:]...)
+			return nil
+		}
+	}
+	return mgo.ErrNotFound
+}
+
+func (c *fakeCollection) EnsureIndex(index mgo.Index) error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+	c.db.indexes[c.name] = append(c.db.indexes[c.name], index)
+	return nil
+}
+
+func (c *fakeCollection) checkUnique(doc bson.M) error {
+	for _, index := range c.db.indexes[c.name] {
+		if !index.Unique {
+			continue
+		}
+		query := bson.M{}
+		for _, key := range index.Key {
+			query[key] = doc[key]
+		}
+		for _, old := range c.db.collections[c.name] {
+			if matchDoc(old, query) {
+				return &mgo.LastError{Code: 11000, Err: "E11000 duplicate key error"}
+			}
+		}
+	}
+	return nil
+}
+
+func convertDoc(in, out interface{}) error {
+	data, err := bson.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(data, out)
+}
+
+func matchDoc(doc, query bson.M) bool {
+	for key, want := range query {
+		got, ok := doc[key]
+		ops, isOps := want.(bson.M)
+		if !isOps {
+			if !ok || !equalValue(got, want) {
+				return false
+			}
+			continue
+		}
+		for op, arg := range ops {
+			if op == "$exists" {
+				if ok != arg.(bool) {
+					return false
+				}
+				continue
+			}
+			x, y, numeric := numbers(got, arg)
+			if !ok || !numeric {
+				return false
+			}
+			switch op {
+			case "$gt":
+				ok = x > y
+			case "$gte":
+				ok = x >= y
+			case "$lt":
+				ok = x < y
+			case "$lte":
+				ok = x <= y
+			default:
+				panic("fake mongo: unsupported operator " + op)
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func applyChange(doc, change bson.M, insert bool) error {
+	for op, arg := range change {
+		var fields bson.M
+		if err := convertDoc(arg, &fields); err != nil {
+			return err
+		}
+		switch op {
+		case "$set":
+			for key, value := range fields {
+				doc[key] = value
+			}
+		case "$setOnInsert":
+			if insert {
+				for key, value := range fields {
+					doc[key] = value
+				}
+			}
+		case "$inc":
+			for key, value := range fields {
+				x, y, ok := numbers(doc[key], value)
+				if doc[key] == nil {
+					x, ok = 0, true
+				}
+				if !ok {
+					return fmt.Errorf("fake mongo: $inc on non-numeric %s", key)
+				}
+				doc[key] = int(x + y)
+			}
+		default:
+			panic("fake mongo: unsupported update " + op)
+		}
+	}
+	return nil
+}
+
+func equalValue(a, b interface{}) bool {
+	if x, y, ok := numbers(a, b); ok {
+		return x == y
+	}
+	// Named string types such as store.OrderStatus are stored as strings
+	x, y := reflect.ValueOf(a), reflect.ValueOf(b)
+	if x.Kind() == reflect.String && y.Kind() == reflect.String {
+		return x.String() == y.String()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func numbers(a, b interface{}) (float64, float64, bool) {
+	x, ok1 := number(a)
+	y, ok2 := number(b)
+	return x, y, ok1 && ok2
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
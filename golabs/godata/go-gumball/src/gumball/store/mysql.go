@@ -0,0 +1,398 @@
+/*
+	Gumball API in Go
+	MySQL Backend
+*/
+
+package store
+
+import (
+	"database/sql"
+	"github.com/go-sql-driver/mysql"
+	"time"
+)
+
+/*
+	Go's SQL Package:
+		Tutorial: http://go-database-sql.org/index.html
+		Reference: https://golang.org/pkg/database/sql/
+*/
+
+// MySQL Error Codes
+const mysql_duplicate_entry = 1062
+
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// Adds the fence and claimed_at columns to tables that predate them.
+func OpenMySQLStore(connect string) (*MySQLStore, error) {
+	db, err := sql.Open("mysql", connect)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range mysql_added_columns {
+		if err := addColumn(db, c.table, c.column, c.definition); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return NewMySQLStore(db), nil
+}
+
+// Columns Added Since the Schema Was First Published
+var mysql_added_columns = []struct{ table, column, definition string }{
+	{"gumball", "fence", "bigint(20) NOT NULL DEFAULT 0"},
+	{"idempotency_keys", "claimed_at", "bigint(20) NOT NULL DEFAULT 0"},
+}
+
+// A missing table is left for the schema script to create.
+func addColumn(db *sql.DB, table, column, definition string) error {
+	var columns, found int
+	err := db.QueryRow("select count(*), coalesce(sum(column_name = ?), 0) from information_schema.columns where table_schema = database() and table_name = ?", column, table).
+		Scan(&columns, &found)
+	if err != nil || columns == 0 || found > 0 {
+		return err
+	}
+	_, err = db.Exec("alter table " + table + " add column " + column + " " + definition)
+	return err
+}
+
+// NewMySQLStore uses an open database.
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db}
+}
+
+func (s *MySQLStore) ListMachines() ([]Machine, error) {
+	rows, err := s.db.Query("select count_gumballs, model_number, serial_number, version, fence from gumball")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var machines_array []Machine
+	for rows.Next() {
+		var m Machine
+		if err := rows.Scan(&m.CountGumballs, &m.ModelNumber, &m.SerialNumber, &m.Version, &m.Fence); err != nil {
+			return nil, err
+		}
+		machines_array = append(machines_array, m)
+	}
+	return machines_array, rows.Err()
+}
+
+func (s *MySQLStore) GetMachine(serial string) (Machine, error) {
+	var m Machine
+	err := s.db.QueryRow("select count_gumballs, model_number, serial_number, version, fence from gumball where serial_number = ?", serial).
+		Scan(&m.CountGumballs, &m.ModelNumber, &m.SerialNumber, &m.Version, &m.Fence)
+	if err == sql.ErrNoRows {
+		return Machine{}, ErrNotFound
+	}
+	return m, err
+}
+
+func (s *MySQLStore) RegisterMachine(m Machine) (Machine, error) {
+	if m.CountGumballs < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	_, err := s.db.Exec("insert into gumball ( version, count_gumballs, model_number, serial_number ) values ( 0, ?, ?, ? )",
+		m.CountGumballs, m.ModelNumber, m.SerialNumber)
+	if me, ok := err.(*mysql.MySQLError); ok && me.Number == mysql_duplicate_entry {
+		return Machine{}, ErrExists
+	}
+	if err != nil {
+		return Machine{}, err
+	}
+	m.Version = 0
+	return m, nil
+}
+
+func (s *MySQLStore) RetireMachine(serial string) error {
+	res, err := s.db.Exec("delete from gumball where serial_number = ?", serial)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MySQLStore) UpdateInventory(serial string, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	_, err := s.db.Exec("update gumball set count_gumballs = ?, version = version + 1 where serial_number = ?", count, serial)
+	if err != nil {
+		return Machine{}, err
+	}
+	return s.GetMachine(serial)
+}
+
+func (s *MySQLStore) UpdateInventoryIf(serial string, version int64, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	res, err := s.db.Exec("update gumball set count_gumballs = ?, version = version + 1 where serial_number = ? and version = ?", count, serial, version)
+	if err != nil {
+		return Machine{}, err
+	}
+	m, err := s.GetMachine(serial)
+	if err != nil {
+		return Machine{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return m, ErrVersionMismatch
+	}
+	return m, nil
+}
+
+func (s *MySQLStore) UpdateInventoryFenced(serial string, token, version int64, count int) (Machine, error) {
+	if token < 1 {
+		return Machine{}, ErrStaleToken
+	}
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	res, err := s.db.Exec("update gumball set count_gumballs = ?, version = version + 1, fence = ? where serial_number = ? and version = ? and fence <= ?",
+		count, token, serial, version, token)
+	if err != nil {
+		return Machine{}, err
+	}
+	m, err := s.GetMachine(serial)
+	if err != nil {
+		return Machine{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if m.Fence > token {
+			return m, ErrStaleToken
+		}
+		return m, ErrVersionMismatch
+	}
+	return m, nil
+}
+
+// The insert only succeeds while the machine is registered.
+func (s *MySQLStore) CreateOrder(ord Order) (Order, error) {
+	res, err := s.db.Exec("insert into orders ( id, serial_number, order_status ) select ?, serial_number, ? from gumball where serial_number = ?",
+		ord.Id, ord.OrderStatus, ord.SerialNumber)
+	if err != nil {
+		return Order{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return Order{}, ErrNotFound
+	}
+	return ord, nil
+}
+
+func (s *MySQLStore) GetOrder(id string) (Order, error) {
+	var ord Order
+	err := s.db.QueryRow("select id, serial_number, order_status, reason from orders where id = ?", id).
+		Scan(&ord.Id, &ord.SerialNumber, &ord.OrderStatus, &ord.Reason)
+	if err == sql.ErrNoRows {
+		return Order{}, ErrNotFound
+	}
+	return ord, err
+}
+
+func (s *MySQLStore) ListOrders() ([]Order, error) {
+	rows, err := s.db.Query("select id, serial_number, order_status, reason from orders")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var orders_array []Order
+	for rows.Next() {
+		var ord Order
+		if err := rows.Scan(&ord.Id, &ord.SerialNumber, &ord.OrderStatus, &ord.Reason); err != nil {
+			return nil, err
+		}
+		orders_array = append(orders_array, ord)
+	}
+	return orders_array, rows.Err()
+}
+
+func (s *MySQLStore) ProcessOrder(id string) (Order, error) {
+	return s.processOrder(id, 0)
+}
+
+func (s *MySQLStore) ProcessOrderFenced(id string, token int64) (Order, error) {
+	if token < 1 {
+		return Order{}, ErrStaleToken
+	}
+	return s.processOrder(id, token)
+}
+
+func (s *MySQLStore) processOrder(id string, token int64) (Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Order{}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the order row, then the machine row
+	ord, err := lockOrder(tx, id)
+	if err != nil {
+		return Order{}, err
+	}
+	if !ord.OrderStatus.CanTransition(OrderProcessing) {
+		return ord, ErrInvalidTransition
+	}
+	m := Machine{SerialNumber: ord.SerialNumber}
+	err = tx.QueryRow("select count_gumballs, fence from gumball where serial_number = ? for update", ord.SerialNumber).Scan(&m.CountGumballs, &m.Fence)
+	if err == sql.ErrNoRows {
+		return Order{}, ErrNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	if err := m.fence(token); err != nil {
+		return ord, err
+	}
+
+	var result error
+	if m.CountGumballs < 1 {
+		ord.OrderStatus, ord.Reason, result = OrderFailed, ReasonOutOfStock, ErrOutOfStock
+	} else {
+		ord.OrderStatus = OrderDispensed
+		if _, err := tx.Exec("update gumball set count_gumballs = count_gumballs - 1, version = version + 1, fence = ? where serial_number = ?", m.Fence, ord.SerialNumber); err != nil {
+			return Order{}, err
+		}
+	}
+	if _, err := tx.Exec("update orders set order_status = ?, reason = ? where id = ?", ord.OrderStatus, ord.Reason, id); err != nil {
+		return Order{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Order{}, err
+	}
+	return ord, result
+}
+
+func (s *MySQLStore) CancelOrder(id string) (Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Order{}, err
+	}
+	defer tx.Rollback()
+	ord, err := lockOrder(tx, id)
+	if err != nil {
+		return Order{}, err
+	}
+	if !ord.OrderStatus.CanTransition(OrderCancelled) {
+		return ord, ErrInvalidTransition
+	}
+	ord.OrderStatus = OrderCancelled
+	if _, err := tx.Exec("update orders set order_status = ?, reason = ? where id = ?", ord.OrderStatus, ord.Reason, id); err != nil {
+		return Order{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func lockOrder(tx *sql.Tx, id string) (Order, error) {
+	var ord Order
+	err := tx.QueryRow("select id, serial_number, order_status, reason from orders where id = ? for update", id).
+		Scan(&ord.Id, &ord.SerialNumber, &ord.OrderStatus, &ord.Reason)
+	if err == sql.ErrNoRows {
+		return Order{}, ErrNotFound
+	}
+	return ord, err
+}
+
+// Expired keys are deleted on each claim.
+func (s *MySQLStore) ClaimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	if _, err := s.db.Exec("delete from idempotency_keys where expires_at <= ?", nowMillis()); err != nil {
+		return IdempotencyKey{}, err
+	}
+	k = k.withTTL(ttl)
+	_, err := s.db.Exec("insert into idempotency_keys ( idem_key, fingerprint, order_id, claimed_at, expires_at ) values ( ?, ?, ?, ?, ? )",
+		k.Key, k.Fingerprint, k.OrderId, k.ClaimedAt, k.Expires)
+	if me, ok := err.(*mysql.MySQLError); ok && me.Number == mysql_duplicate_entry {
+		var old IdempotencyKey
+		err := s.db.QueryRow("select idem_key, fingerprint, order_id, claimed_at, expires_at from idempotency_keys where idem_key = ?", k.Key).
+			Scan(&old.Key, &old.Fingerprint, &old.OrderId, &old.ClaimedAt, &old.Expires)
+		if err != nil {
+			return IdempotencyKey{}, err
+		}
+		return old, ErrExists
+	}
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	return k, nil
+}
+
+func (s *MySQLStore) ReclaimKey(old, k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	k = k.withTTL(ttl)
+	res, err := s.db.Exec("update idempotency_keys set fingerprint = ?, order_id = ?, claimed_at = ?, expires_at = ? where idem_key = ? and order_id = ?",
+		k.Fingerprint, k.OrderId, k.ClaimedAt, k.Expires, k.Key, old.OrderId)
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return IdempotencyKey{}, err
+	} else if n == 0 {
+		return s.ClaimKey(k, ttl)
+	}
+	return k, nil
+}
+
+func (s *MySQLStore) ReleaseKey(key string) error {
+	_, err := s.db.Exec("delete from idempotency_keys where idem_key = ?", key)
+	return err
+}
+
+func (s *MySQLStore) Close() error {
+	return s.db.Close()
+}
+
+/*
+
+	-- Create Database Schema (DB User: root, DB Pass: cmpe281)
+
+		create database cmpe281 ;
+		use cmpe281 ;
+
+	-- Create Database Tables
+
+		CREATE TABLE gumball (
+		  id bigint(20) NOT NULL AUTO_INCREMENT,
+		  version bigint(20) NOT NULL,
+		  count_gumballs int(11) NOT NULL,
+		  model_number varchar(255) NOT NULL,
+		  serial_number varchar(255) NOT NULL,
+		  fence bigint(20) NOT NULL DEFAULT 0,
+		  PRIMARY KEY (id),
+		  UNIQUE KEY serial_number (serial_number)
+		) ;
+
+	-- Add the fencing token and claim time columns to existing
+	-- tables (done on startup if they are missing)
+
+		ALTER TABLE gumball ADD COLUMN fence bigint(20) NOT NULL DEFAULT 0 ;
+		ALTER TABLE idempotency_keys ADD COLUMN claimed_at bigint(20) NOT NULL DEFAULT 0 ;
+
+		CREATE TABLE orders (
+		  id varchar(36) NOT NULL,
+		  serial_number varchar(255) NOT NULL,
+		  order_status varchar(255) NOT NULL,
+		  reason varchar(255) NOT NULL DEFAULT '',
+		  PRIMARY KEY (id),
+		  KEY serial_number (serial_number)
+		) ;
+
+		CREATE TABLE idempotency_keys (
+		  idem_key varchar(255) NOT NULL,
+		  fingerprint varchar(64) NOT NULL,
+		  order_id varchar(36) NOT NULL,
+		  claimed_at bigint(20) NOT NULL DEFAULT 0,
+		  expires_at bigint(20) NOT NULL,
+		  PRIMARY KEY (idem_key),
+		  KEY expires_at (expires_at)
+		) ;
+
+	-- The gumball row for the configured serial number is
+	-- inserted on startup if it does not already exist.
+	-- Other machines are added with POST /gumball.
+
+*/
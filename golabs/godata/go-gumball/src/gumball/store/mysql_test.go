@@ -0,0 +1,387 @@
+package store_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"gumball/store"
+	"gumball/store/storetest"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMySQLStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		db, err := sql.Open("fakemysql", newFakeSQL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store.NewMySQLStore(db)
+	})
+}
+
+// MySQL Stand-In
+// A database/sql driver that understands exactly the statements
+// MySQLStore issues.  Transactions run one at a time, which gives
+// "select ... for update" its locking behaviour, and are undone
+// on rollback.
+func init() {
+	sql.Register("fakemysql", fakeDriver{})
+}
+
+type fakeGumball struct {
+	count   int64
+	model   string
+	serial  string
+	version int64
+	fence   int64
+}
+
+type fakeOrder struct {
+	serial string
+	status string
+	reason string
+}
+
+type fakeKey struct {
+	fingerprint string
+	order       string
+	claimed     int64
+	expires     int64
+}
+
+type fakeDatabase struct {
+	mutex   sync.Mutex
+	tx      sync.Mutex
+	gumball []*fakeGumball
+	orders  map[string]fakeOrder
+	order   []string
+	keys    map[string]fakeKey
+}
+
+var fakeDatabases = struct {
+	sync.Mutex
+	next int
+	dbs  map[string]*fakeDatabase
+}{dbs: make(map[string]*fakeDatabase)}
+
+// newFakeSQL returns the data source name of a new empty database.
+func newFakeSQL() string {
+	fakeDatabases.Lock()
+	defer fakeDatabases.Unlock()
+	fakeDatabases.next++
+	name := fmt.Sprintf("db%d", fakeDatabases.next)
+	fakeDatabases.dbs[name] = &fakeDatabase{orders: make(map[string]fakeOrder), keys: make(map[string]fakeKey)}
+	return name
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDatabases.Lock()
+	defer fakeDatabases.Unlock()
+	db, ok := fakeDatabases.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("fake mysql: unknown database %q", name)
+	}
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct {
+	db   *fakeDatabase
+	undo []func()
+	inTx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	handler, ok := fakeStatements[query]
+	if !ok {
+		return nil, fmt.Errorf("fake mysql: unsupported statement %q", query)
+	}
+	return &fakeStmt{conn: c, handler: handler}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.tx.Lock()
+	c.inTx = true
+	c.undo = nil
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.inTx = false
+	c.undo = nil
+	c.db.tx.Unlock()
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.mutex.Lock()
+	for i := len(c.undo) - 1; i >= 0; i-- {
+		c.undo[i]()
+	}
+	c.db.mutex.Unlock()
+	return c.Commit()
+}
+
+// onRollback records how to undo a change made inside a transaction.
+func (c *fakeConn) onRollback(undo func()) {
+	if c.inTx {
+		c.undo = append(c.undo, undo)
+	}
+}
+
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+	changed int64
+}
+
+type fakeHandler func(c *fakeConn, args []driver.Value) (*fakeResult, error)
+
+type fakeStmt struct {
+	conn    *fakeConn
+	handler fakeHandler
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.db.mutex.Lock()
+	defer s.conn.db.mutex.Unlock()
+	res, err := s.handler(s.conn, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.changed), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.db.mutex.Lock()
+	defer s.conn.db.mutex.Unlock()
+	res, err := s.handler(s.conn, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: res}, nil
+}
+
+type fakeRows struct {
+	result *fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+func (db *fakeDatabase) findGumball(serial driver.Value) *fakeGumball {
+	for _, g := range db.gumball {
+		if g.serial == serial.(string) {
+			return g
+		}
+	}
+	return nil
+}
+
+func (c *fakeConn) selectGumball(g *fakeGumball, res *fakeResult) {
+	res.rows = append(res.rows, []driver.Value{g.count, g.model, g.serial, g.version, g.fence})
+}
+
+// setCount bumps the version as well.
+func (c *fakeConn) setCount(g *fakeGumball, count int64) {
+	old, version := g.count, g.version
+	g.count = count
+	g.version++
+	c.onRollback(func() { g.count, g.version = old, version })
+}
+
+func (c *fakeConn) setFence(g *fakeGumball, fence int64) {
+	old := g.fence
+	g.fence = fence
+	c.onRollback(func() { g.fence = old })
+}
+
+func (c *fakeConn) setOrder(id string, ord fakeOrder) {
+	old := c.db.orders[id]
+	c.db.orders[id] = ord
+	c.onRollback(func() { c.db.orders[id] = old })
+}
+
+var fakeStatements = map[string]fakeHandler{
+	"insert into gumball ( version, count_gumballs, model_number, serial_number ) values ( 0, ?, ?, ? )": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		if c.db.findGumball(args[2]) != nil {
+			return nil, &mysql.MySQLError{Number: 1062, Message: fmt.Sprintf("Duplicate entry %q for key 'serial_number'", args[2])}
+		}
+		c.db.gumball = append(c.db.gumball, &fakeGumball{
+			count:  args[0].(int64),
+			model:  args[1].(string),
+			serial: args[2].(string),
+		})
+		return &fakeResult{changed: 1}, nil
+	},
+	"select count_gumballs, model_number, serial_number, version, fence from gumball": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		res := &fakeResult{columns: []string{"count_gumballs", "model_number", "serial_number", "version", "fence"}}
+		for _, g := range c.db.gumball {
+			c.selectGumball(g, res)
+		}
+		return res, nil
+	},
+	"select count_gumballs, model_number, serial_number, version, fence from gumball where serial_number = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		res := &fakeResult{columns: []string{"count_gumballs", "model_number", "serial_number", "version", "fence"}}
+		if g := c.db.findGumball(args[0]); g != nil {
+			c.selectGumball(g, res)
+		}
+		return res, nil
+	},
+	"delete from gumball where serial_number = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		for i, g := range c.db.gumball {
+			if g.serial == args[0].(string) {
+				c.db.gumball = append(c.db.gumball[:i:i], c.db.gumball[i+1:]...)
+				return &fakeResult{changed: 1}, nil
+			}
+		}
+		return &fakeResult{}, nil
+	},
+	"select count_gumballs, fence from gumball where serial_number = ? for update": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		res := &fakeResult{columns: []string{"count_gumballs", "fence"}}
+		if g := c.db.findGumball(args[0]); g != nil {
+			res.rows = append(res.rows, []driver.Value{g.count, g.fence})
+		}
+		return res, nil
+	},
+	"update gumball set count_gumballs = ?, version = version + 1 where serial_number = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		g := c.db.findGumball(args[1])
+		if g == nil {
+			return &fakeResult{}, nil
+		}
+		c.setCount(g, args[0].(int64))
+		return &fakeResult{changed: 1}, nil
+	},
+	"update gumball set count_gumballs = ?, version = version + 1 where serial_number = ? and version = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		g := c.db.findGumball(args[1])
+		if g == nil || g.version != args[2].(int64) {
+			return &fakeResult{}, nil
+		}
+		c.setCount(g, args[0].(int64))
+		return &fakeResult{changed: 1}, nil
+	},
+	"update gumball set count_gumballs = ?, version = version + 1, fence = ? where serial_number = ? and version = ? and fence <= ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		g := c.db.findGumball(args[2])
+		if g == nil || g.version != args[3].(int64) || g.fence > args[4].(int64) {
+			return &fakeResult{}, nil
+		}
+		c.setCount(g, args[0].(int64))
+		c.setFence(g, args[1].(int64))
+		return &fakeResult{changed: 1}, nil
+	},
+	"update gumball set count_gumballs = count_gumballs - 1, version = version + 1, fence = ? where serial_number = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		g := c.db.findGumball(args[1])
+		if g == nil {
+			return &fakeResult{}, nil
+		}
+		c.setCount(g, g.count-1)
+		c.setFence(g, args[0].(int64))
+		return &fakeResult{changed: 1}, nil
+	},
+	"insert into orders ( id, serial_number, order_status ) select ?, serial_number, ? from gumball where serial_number = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		id := args[0].(string)
+		if _, ok := c.db.orders[id]; ok {
+			return nil, &mysql.MySQLError{Number: 1062, Message: fmt.Sprintf("Duplicate entry %q for key 'PRIMARY'", id)}
+		}
+		g := c.db.findGumball(args[2])
+		if g == nil {
+			return &fakeResult{}, nil
+		}
+		c.db.orders[id] = fakeOrder{serial: g.serial, status: args[1].(string)}
+		c.db.order = append(c.db.order, id)
+		return &fakeResult{changed: 1}, nil
+	},
+	"select id, serial_number, order_status, reason from orders where id = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		return c.selectOrder(args[0].(string)), nil
+	},
+	"select id, serial_number, order_status, reason from orders where id = ? for update": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		return c.selectOrder(args[0].(string)), nil
+	},
+	"select id, serial_number, order_status, reason from orders": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		res := &fakeResult{columns: []string{"id", "serial_number", "order_status", "reason"}}
+		for _, id := range c.db.order {
+			ord := c.db.orders[id]
+			res.rows = append(res.rows, []driver.Value{id, ord.serial, ord.status, ord.reason})
+		}
+		return res, nil
+	},
+	"update orders set order_status = ?, reason = ? where id = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		id := args[2].(string)
+		if _, ok := c.db.orders[id]; !ok {
+			return &fakeResult{}, nil
+		}
+		ord := c.db.orders[id]
+		ord.status, ord.reason = args[0].(string), args[1].(string)
+		c.setOrder(id, ord)
+		return &fakeResult{changed: 1}, nil
+	},
+	"delete from idempotency_keys where expires_at <= ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		res := &fakeResult{}
+		for key, k := range c.db.keys {
+			if k.expires <= args[0].(int64) {
+				delete(c.db.keys, key)
+				res.changed++
+			}
+		}
+		return res, nil
+	},
+	"insert into idempotency_keys ( idem_key, fingerprint, order_id, claimed_at, expires_at ) values ( ?, ?, ?, ?, ? )": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		key := args[0].(string)
+		if _, ok := c.db.keys[key]; ok {
+			return nil, &mysql.MySQLError{Number: 1062, Message: fmt.Sprintf("Duplicate entry %q for key 'PRIMARY'", key)}
+		}
+		c.db.keys[key] = fakeKey{fingerprint: args[1].(string), order: args[2].(string), claimed: args[3].(int64), expires: args[4].(int64)}
+		return &fakeResult{changed: 1}, nil
+	},
+	"select idem_key, fingerprint, order_id, claimed_at, expires_at from idempotency_keys where idem_key = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		res := &fakeResult{columns: []string{"idem_key", "fingerprint", "order_id", "claimed_at", "expires_at"}}
+		if k, ok := c.db.keys[args[0].(string)]; ok {
+			res.rows = append(res.rows, []driver.Value{args[0], k.fingerprint, k.order, k.claimed, k.expires})
+		}
+		return res, nil
+	},
+	"update idempotency_keys set fingerprint = ?, order_id = ?, claimed_at = ?, expires_at = ? where idem_key = ? and order_id = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		key := args[4].(string)
+		if k, ok := c.db.keys[key]; !ok || k.order != args[5].(string) {
+			return &fakeResult{}, nil
+		}
+		c.db.keys[key] = fakeKey{fingerprint: args[0].(string), order: args[1].(string), claimed: args[2].(int64), expires: args[3].(int64)}
+		return &fakeResult{changed: 1}, nil
+	},
+	"delete from idempotency_keys where idem_key = ?": func(c *fakeConn, args []driver.Value) (*fakeResult, error) {
+		if _, ok := c.db.keys[args[0].(string)]; !ok {
+			return &fakeResult{}, nil
+		}
+		delete(c.db.keys, args[0].(string))
+		return &fakeResult{changed: 1}, nil
+	},
+}
+
+func (c *fakeConn) selectOrder(id string) *fakeResult {
+	res := &fakeResult{columns: []string{"id", "serial_number", "order_status", "reason"}}
+	if ord, ok := c.db.orders[id]; ok {
+		res.rows = append(res.rows, []driver.Value{id, ord.serial, ord.status, ord.reason})
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+/*
+	Gumball API in Go
+	Order Lifecycle
+*/
+
+package store
+
+/*
+	Order State Machine
+
+		Placed ---> Processing ---> Dispensed
+		  |              |
+		  v              v
+		Cancelled      Failed (Out of Stock)
+
+	Dispensed, Cancelled and Failed are final.
+*/
+
+type OrderStatus string
+
+const (
+	OrderPlaced     OrderStatus = "Order Placed"
+	OrderProcessing OrderStatus = "Order Processing"
+	OrderDispensed  OrderStatus = "Order Dispensed"
+	OrderCancelled  OrderStatus = "Order Cancelled"
+	OrderFailed     OrderStatus = "Order Failed"
+)
+
+// Failure Reasons
+const ReasonOutOfStock = "Out of Stock"
+
+var order_transitions = map[OrderStatus][]OrderStatus{
+	OrderPlaced:     {OrderProcessing, OrderCancelled},
+	OrderProcessing: {OrderDispensed, OrderFailed},
+}
+
+// CanTransition reports whether an order may move from s to next.
+func (s OrderStatus) CanTransition(next OrderStatus) bool {
+	for _, to := range order_transitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Final reports whether no further transitions are possible.
+func (s OrderStatus) Final() bool {
+	return len(order_transitions[s]) == 0
+}
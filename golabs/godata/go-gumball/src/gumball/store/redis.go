@@ -0,0 +1,464 @@
+/*
+	Gumball API in Go
+	Redis Backend
+*/
+
+package store
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis"
+	"strconv"
+	"time"
+)
+
+/*
+	Go's Redis Package:
+		Github: https://github.com/go-redis/redis
+		Example: https://github.com/go-redis/redis/blob/master/example_test.go
+
+	Keys:
+		gumball:machine:{serial}	hash of machine fields, Version and Fence
+		gumball:machines		set of machine serial numbers
+		gumball:order:{id}		hash of order fields
+		gumball:orders			set of order ids
+		gumball:idempotency:{key}	JSON idempotency key, expires with PX
+*/
+
+const redis_machines = "gumball:machines"
+const redis_orders = "gumball:orders"
+
+// Register Machine Atomically
+// KEYS[1] machine hash, KEYS[2] machines set
+// ARGV count, model and serial number
+// Returns 0 if the machine already exists
+var redis_register = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'CountGumballs', ARGV[1], 'ModelNumber', ARGV[2], 'SerialNumber', ARGV[3], 'Version', 0)
+redis.call('SADD', KEYS[2], ARGV[3])
+return 1
+`)
+
+// Retire Machine Atomically
+// KEYS[1] machine hash, KEYS[2] machines set, ARGV serial number
+// Returns 0 if there was no such machine
+var redis_retire = redis.NewScript(`
+redis.call('SREM', KEYS[2], ARGV[1])
+return redis.call('DEL', KEYS[1])
+`)
+
+// Set Inventory of an Existing Machine
+// KEYS[1] machine hash, ARGV count, optional expected version and
+// optional fencing token
+// Returns 0 if the machine is not at the expected version, -2 if
+// the token is stale
+var redis_inventory = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return redis.error_reply('not found')
+end
+local token = tonumber(ARGV[3] or '0')
+if token > 0 and tonumber(redis.call('HGET', KEYS[1], 'Fence') or '0') > token then
+	return -2
+end
+local version = redis.call('HGET', KEYS[1], 'Version') or '0'
+if ARGV[2] and version ~= ARGV[2] then
+	return 0
+end
+if token > 0 then
+	redis.call('HSET', KEYS[1], 'Fence', token)
+end
+redis.call('HSET', KEYS[1], 'CountGumballs', ARGV[1])
+redis.call('HINCRBY', KEYS[1], 'Version', 1)
+return 1
+`)
+
+// Create Order on an Existing Machine
+// KEYS[1] machine hash, KEYS[2] order hash, KEYS[3] orders set
+// ARGV id, serial number, status and reason
+var redis_create_order = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return redis.error_reply('not found')
+end
+redis.call('HSET', KEYS[2], 'Id', ARGV[1], 'SerialNumber', ARGV[2], 'OrderStatus', ARGV[3], 'Reason', ARGV[4])
+redis.call('SADD', KEYS[3], ARGV[1])
+return 1
+`)
+
+// Process Order Atomically
+// KEYS[1] order hash, KEYS[2] machine hash
+// ARGV placed, dispensed, failed status, out of stock reason and
+// fencing token (0 if unfenced)
+// Returns 0 if not placed, -1 if out of stock, -2 if the token is
+// stale, 1 if dispensed
+var redis_process = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], 'OrderStatus')
+if not status then
+	return redis.error_reply('not found')
+end
+if status ~= ARGV[1] then
+	return 0
+end
+local count = redis.call('HGET', KEYS[2], 'CountGumballs')
+if not count then
+	return redis.error_reply('not found')
+end
+local token = tonumber(ARGV[5])
+if token > 0 and tonumber(redis.call('HGET', KEYS[2], 'Fence') or '0') > token then
+	return -2
+end
+count = tonumber(count)
+if count < 1 then
+	redis.call('HSET', KEYS[1], 'OrderStatus', ARGV[3], 'Reason', ARGV[4])
+	return -1
+end
+if token > 0 then
+	redis.call('HSET', KEYS[2], 'Fence', token)
+end
+redis.call('HINCRBY', KEYS[2], 'CountGumballs', -1)
+redis.call('HINCRBY', KEYS[2], 'Version', 1)
+redis.call('HSET', KEYS[1], 'OrderStatus', ARGV[2])
+return 1
+`)
+
+// Change Order Status Atomically
+// KEYS[1] order hash, ARGV from and to status
+// Returns 0 if the order is not in the from status
+var redis_transition = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], 'OrderStatus')
+if not status then
+	return redis.error_reply('not found')
+end
+if status ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'OrderStatus', ARGV[2])
+return 1
+`)
+
+// Claim Idempotency Key
+// KEYS[1] key, ARGV JSON key and TTL in milliseconds
+// Returns the stored JSON if the key is already claimed
+var redis_claim = redis.NewScript(`
+local old = redis.call('GET', KEYS[1])
+if old then
+	return old
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// Reclaim Idempotency Key
+// KEYS[1] key, ARGV order id of the abandoned claim, JSON key and
+// TTL in milliseconds
+// Returns the stored JSON if the key now names another order
+var redis_reclaim = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur and cjson.decode(cur)['OrderId'] ~= ARGV[1] then
+	return cur
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return false
+`)
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func OpenRedisStore(connect string) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     connect,
+		Password: "", // no password set
+		DB:       0,  // use default DB
+	})
+	if err := client.Ping().Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return NewRedisStore(client), nil
+}
+
+// NewRedisStore uses a connected client.
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func machineKey(serial string) string {
+	return "gumball:machine:" + serial
+}
+
+func orderKey(id string) string {
+	return "gumball:order:" + id
+}
+
+func idempotencyKey(key string) string {
+	return "gumball:idempotency:" + key
+}
+
+func (s *RedisStore) ListMachines() ([]Machine, error) {
+	serials, err := s.client.SMembers(redis_machines).Result()
+	if err != nil {
+		return nil, err
+	}
+	var machines_array []Machine
+	for _, serial := range serials {
+		m, err := s.GetMachine(serial)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		machines_array = append(machines_array, m)
+	}
+	return machines_array, nil
+}
+
+func (s *RedisStore) GetMachine(serial string) (Machine, error) {
+	fields, err := s.client.HGetAll(machineKey(serial)).Result()
+	if err != nil {
+		return Machine{}, err
+	}
+	if len(fields) == 0 {
+		return Machine{}, ErrNotFound
+	}
+	count, _ := strconv.Atoi(fields["CountGumballs"])
+	version, _ := strconv.ParseInt(fields["Version"], 10, 64)
+	fence, _ := strconv.ParseInt(fields["Fence"], 10, 64)
+	return Machine{
+		CountGumballs: count,
+		ModelNumber:   fields["ModelNumber"],
+		SerialNumber:  fields["SerialNumber"],
+		Version:       version,
+		Fence:         fence,
+	}, nil
+}
+
+func (s *RedisStore) RegisterMachine(m Machine) (Machine, error) {
+	if m.CountGumballs < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	n, err := runScript(s.client, redis_register, []string{machineKey(m.SerialNumber), redis_machines},
+		m.CountGumballs, m.ModelNumber, m.SerialNumber)
+	if err != nil {
+		return Machine{}, err
+	}
+	if n == 0 {
+		return Machine{}, ErrExists
+	}
+	return m, nil
+}
+
+func (s *RedisStore) RetireMachine(serial string) error {
+	n, err := runScript(s.client, redis_retire, []string{machineKey(serial), redis_machines}, serial)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *RedisStore) UpdateInventory(serial string, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	if _, err := runScript(s.client, redis_inventory, []string{machineKey(serial)}, count); err != nil {
+		return Machine{}, err
+	}
+	return s.GetMachine(serial)
+}
+
+func (s *RedisStore) UpdateInventoryIf(serial string, version int64, count int) (Machine, error) {
+	return s.updateInventoryIf(serial, 0, version, count)
+}
+
+func (s *RedisStore) UpdateInventoryFenced(serial string, token, version int64, count int) (Machine, error) {
+	if token < 1 {
+		return Machine{}, ErrStaleToken
+	}
+	return s.updateInventoryIf(serial, token, version, count)
+}
+
+func (s *RedisStore) updateInventoryIf(serial string, token, version int64, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	n, err := runScript(s.client, redis_inventory, []string{machineKey(serial)}, count, version, token)
+	if err != nil {
+		return Machine{}, err
+	}
+	m, err := s.GetMachine(serial)
+	if err == nil && n == 0 {
+		err = ErrVersionMismatch
+	}
+	if err == nil && n == -2 {
+		err = ErrStaleToken
+	}
+	return m, err
+}
+
+func (s *RedisStore) CreateOrder(ord Order) (Order, error) {
+	keys := []string{machineKey(ord.SerialNumber), orderKey(ord.Id), redis_orders}
+	_, err := runScript(s.client, redis_create_order, keys,
+		ord.Id, ord.SerialNumber, string(ord.OrderStatus), ord.Reason)
+	if err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *RedisStore) GetOrder(id string) (Order, error) {
+	fields, err := s.client.HGetAll(orderKey(id)).Result()
+	if err != nil {
+		return Order{}, err
+	}
+	if len(fields) == 0 {
+		return Order{}, ErrNotFound
+	}
+	return Order{
+		Id:           fields["Id"],
+		SerialNumber: fields["SerialNumber"],
+		OrderStatus:  OrderStatus(fields["OrderStatus"]),
+		Reason:       fields["Reason"],
+	}, nil
+}
+
+func (s *RedisStore) ListOrders() ([]Order, error) {
+	ids, err := s.client.SMembers(redis_orders).Result()
+	if err != nil {
+		return nil, err
+	}
+	var orders_array []Order
+	for _, id := range ids {
+		ord, err := s.GetOrder(id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		orders_array = append(orders_array, ord)
+	}
+	return orders_array, nil
+}
+
+func (s *RedisStore) ProcessOrder(id string) (Order, error) {
+	return s.processOrder(id, 0)
+}
+
+func (s *RedisStore) ProcessOrderFenced(id string, token int64) (Order, error) {
+	if token < 1 {
+		return Order{}, ErrStaleToken
+	}
+	return s.processOrder(id, token)
+}
+
+// An order's machine never changes, so it is looked up before
+// running the script.
+func (s *RedisStore) processOrder(id string, token int64) (Order, error) {
+	ord, err := s.GetOrder(id)
+	if err != nil {
+		return Order{}, err
+	}
+	keys := []string{orderKey(id), machineKey(ord.SerialNumber)}
+	n, err := runScript(s.client, redis_process, keys,
+		string(OrderPlaced), string(OrderDispensed), string(OrderFailed), ReasonOutOfStock, token)
+	if err != nil {
+		return Order{}, err
+	}
+	switch n {
+	case -2:
+		return ord, ErrStaleToken
+	case -1:
+		ord.OrderStatus, ord.Reason = OrderFailed, ReasonOutOfStock
+		return ord, ErrOutOfStock
+	case 0:
+		ord, err := s.GetOrder(id)
+		if err != nil {
+			return Order{}, err
+		}
+		return ord, ErrInvalidTransition
+	}
+	ord.OrderStatus = OrderDispensed
+	return ord, nil
+}
+
+func (s *RedisStore) CancelOrder(id string) (Order, error) {
+	n, err := runScript(s.client, redis_transition, []string{orderKey(id)},
+		string(OrderPlaced), string(OrderCancelled))
+	if err != nil {
+		return Order{}, err
+	}
+	ord, err := s.GetOrder(id)
+	if err != nil {
+		return Order{}, err
+	}
+	if n == 0 {
+		return ord, ErrInvalidTransition
+	}
+	return ord, nil
+}
+
+func (s *RedisStore) ClaimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	k = k.withTTL(ttl)
+	data, err := json.Marshal(k)
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	res, err := redis_claim.Run(s.client, []string{idempotencyKey(k.Key)}, data, int64(ttl/time.Millisecond)).Result()
+	if err == redis.Nil {
+		return k, nil
+	}
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	var old IdempotencyKey
+	if err := json.Unmarshal([]byte(res.(string)), &old); err != nil {
+		return IdempotencyKey{}, err
+	}
+	return old, ErrExists
+}
+
+func (s *RedisStore) ReclaimKey(old, k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	k = k.withTTL(ttl)
+	data, err := json.Marshal(k)
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	res, err := redis_reclaim.Run(s.client, []string{idempotencyKey(k.Key)}, old.OrderId, data, int64(ttl/time.Millisecond)).Result()
+	if err == redis.Nil {
+		return k, nil
+	}
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+	var cur IdempotencyKey
+	if err := json.Unmarshal([]byte(res.(string)), &cur); err != nil {
+		return IdempotencyKey{}, err
+	}
+	return cur, ErrExists
+}
+
+func (s *RedisStore) ReleaseKey(key string) error {
+	return s.client.Del(idempotencyKey(key)).Err()
+}
+
+// Run Script Returning an Integer
+func runScript(client *redis.Client, script *redis.Script, keys []string, args ...interface{}) (int64, error) {
+	res, err := script.Run(client, keys, args...).Result()
+	if err != nil {
+		if err.Error() == "not found" {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	n, _ := res.(int64)
+	return n, nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
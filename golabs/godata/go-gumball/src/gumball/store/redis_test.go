@@ -0,0 +1,39 @@
+package store_test
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"gumball/store"
+	"gumball/store/storetest"
+	"testing"
+	"time"
+)
+
+// Redis Stand-In
+// miniredis speaks the Redis protocol in-process and runs Lua.
+func TestRedisStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		mr := miniredis.RunT(t)
+		runClock(t, mr)
+		return store.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	})
+}
+
+// miniredis only expires keys when its clock is moved, so move it
+// along with the real one.
+func runClock(t *testing.T, mr *miniredis.Miniredis) {
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go func() {
+		tick := time.NewTicker(time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				mr.FastForward(time.Millisecond)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
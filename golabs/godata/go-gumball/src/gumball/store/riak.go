@@ -0,0 +1,433 @@
+/*
+	Gumball API in Go
+	Riak KV Backend
+*/
+
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+/*
+	Riak HTTP API:
+		Reference: http://docs.basho.com/riak/kv/2.2.3/developing/api/http/
+
+	Buckets:
+		gumball		machine documents keyed by serial number
+		orders		order documents keyed by order id
+		idempotency	idempotency keys, replaced once expired and
+				swept every riak_key_sweep
+
+	Riak has no multi-key transactions, so machine and order
+	changes are serialized within this process only.  Fencing tokens
+	are checked the same way; across replicas they rely on every
+	writer holding the machine's lock.
+*/
+
+// Expired Idempotency Key Sweep
+// Riak has no per-key TTL over HTTP, so ClaimKey deletes expired
+// keys at most this often.
+var riak_key_sweep = time.Minute
+
+type RiakStore struct {
+	mutex    sync.Mutex
+	endpoint string
+	client   *http.Client
+	swept    time.Time
+}
+
+func OpenRiakStore(endpoint string) (*RiakStore, error) {
+	s := &RiakStore{
+		endpoint: endpoint,
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConns:    10,
+				IdleConnTimeout: 30 * time.Second,
+			},
+			Timeout: 10 * time.Second,
+		},
+	}
+	resp, err := s.client.Get(endpoint + "/ping")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("riak: ping: %s", resp.Status)
+	}
+	return s, nil
+}
+
+func (s *RiakStore) keyURL(bucket, key string) string {
+	return s.endpoint + "/buckets/" + bucket + "/keys/" + url.PathEscape(key)
+}
+
+func (s *RiakStore) get(bucket, key string, v interface{}) error {
+	resp, err := s.client.Get(s.keyURL(bucket, key))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("riak: GET %s/%s: %s", bucket, key, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (s *RiakStore) put(bucket, key string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", s.keyURL(bucket, key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("riak: PUT %s/%s: %s", bucket, key, resp.Status)
+	}
+	return nil
+}
+
+func (s *RiakStore) delete(bucket, key string) error {
+	req, err := http.NewRequest("DELETE", s.keyURL(bucket, key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("riak: DELETE %s/%s: %s", bucket, key, resp.Status)
+	}
+	return nil
+}
+
+func (s *RiakStore) keys(bucket string) ([]string, error) {
+	resp, err := s.client.Get(s.endpoint + "/buckets/" + bucket + "/keys?keys=true")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("riak: list %s: %s", bucket, resp.Status)
+	}
+	var result struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Keys, nil
+}
+
+func (s *RiakStore) ListMachines() ([]Machine, error) {
+	keys, err := s.keys("gumball")
+	if err != nil {
+		return nil, err
+	}
+	var machines_array []Machine
+	for _, key := range keys {
+		m, err := s.GetMachine(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		machines_array = append(machines_array, m)
+	}
+	return machines_array, nil
+}
+
+func (s *RiakStore) GetMachine(serial string) (Machine, error) {
+	var m Machine
+	if err := s.get("gumball", serial, &m); err != nil {
+		return Machine{}, err
+	}
+	return m, nil
+}
+
+func (s *RiakStore) RegisterMachine(m Machine) (Machine, error) {
+	if m.CountGumballs < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.GetMachine(m.SerialNumber)
+	if err == nil {
+		return Machine{}, ErrExists
+	}
+	if err != ErrNotFound {
+		return Machine{}, err
+	}
+	m.Version = 0
+	if err := s.put("gumball", m.SerialNumber, m); err != nil {
+		return Machine{}, err
+	}
+	return m, nil
+}
+
+func (s *RiakStore) RetireMachine(serial string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.GetMachine(serial); err != nil {
+		return err
+	}
+	return s.delete("gumball", serial)
+}
+
+func (s *RiakStore) UpdateInventory(serial string, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, err := s.GetMachine(serial)
+	if err != nil {
+		return Machine{}, err
+	}
+	return s.putInventory(m, count)
+}
+
+func (s *RiakStore) UpdateInventoryIf(serial string, version int64, count int) (Machine, error) {
+	return s.updateInventoryIf(serial, 0, version, count)
+}
+
+func (s *RiakStore) UpdateInventoryFenced(serial string, token, version int64, count int) (Machine, error) {
+	if token < 1 {
+		return Machine{}, ErrStaleToken
+	}
+	return s.updateInventoryIf(serial, token, version, count)
+}
+
+func (s *RiakStore) updateInventoryIf(serial string, token, version int64, count int) (Machine, error) {
+	if count < 0 {
+		return Machine{}, ErrInvalidInventory
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	m, err := s.GetMachine(serial)
+	if err != nil {
+		return Machine{}, err
+	}
+	current := m
+	if err := m.fence(token); err != nil {
+		return current, err
+	}
+	if m.Version != version {
+		return current, ErrVersionMismatch
+	}
+	return s.putInventory(m, count)
+}
+
+func (s *RiakStore) putInventory(m Machine, count int) (Machine, error) {
+	m.CountGumballs = count
+	m.Version++
+	if err := s.put("gumball", m.SerialNumber, m); err != nil {
+		return Machine{}, err
+	}
+	return m, nil
+}
+
+func (s *RiakStore) CreateOrder(ord Order) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.GetMachine(ord.SerialNumber); err != nil {
+		return Order{}, err
+	}
+	if err := s.put("orders", ord.Id, ord); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *RiakStore) GetOrder(id string) (Order, error) {
+	var ord Order
+	if err := s.get("orders", id, &ord); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *RiakStore) ListOrders() ([]Order, error) {
+	keys, err := s.keys("orders")
+	if err != nil {
+		return nil, err
+	}
+	var orders_array []Order
+	for _, key := range keys {
+		ord, err := s.GetOrder(key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		orders_array = append(orders_array, ord)
+	}
+	return orders_array, nil
+}
+
+func (s *RiakStore) ProcessOrder(id string) (Order, error) {
+	return s.processOrder(id, 0)
+}
+
+func (s *RiakStore) ProcessOrderFenced(id string, token int64) (Order, error) {
+	if token < 1 {
+		return Order{}, ErrStaleToken
+	}
+	return s.processOrder(id, token)
+}
+
+func (s *RiakStore) processOrder(id string, token int64) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ord, err := s.GetOrder(id)
+	if err != nil {
+		return Order{}, err
+	}
+	if !ord.OrderStatus.CanTransition(OrderProcessing) {
+		return ord, ErrInvalidTransition
+	}
+	m, err := s.GetMachine(ord.SerialNumber)
+	if err != nil {
+		return Order{}, err
+	}
+	if err := m.fence(token); err != nil {
+		return ord, err
+	}
+	if m.CountGumballs < 1 {
+		ord.OrderStatus, ord.Reason = OrderFailed, ReasonOutOfStock
+		if err := s.put("orders", id, ord); err != nil {
+			return Order{}, err
+		}
+		return ord, ErrOutOfStock
+	}
+
+	// Mark processing first so a crash never dispenses twice
+	ord.OrderStatus = OrderProcessing
+	if err := s.put("orders", id, ord); err != nil {
+		return Order{}, err
+	}
+	m.CountGumballs -= 1
+	m.Version++
+	if err := s.put("gumball", m.SerialNumber, m); err != nil {
+		return Order{}, err
+	}
+	ord.OrderStatus = OrderDispensed
+	if err := s.put("orders", id, ord); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *RiakStore) CancelOrder(id string) (Order, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ord, err := s.GetOrder(id)
+	if err != nil {
+		return Order{}, err
+	}
+	if !ord.OrderStatus.CanTransition(OrderCancelled) {
+		return ord, ErrInvalidTransition
+	}
+	ord.OrderStatus = OrderCancelled
+	if err := s.put("orders", id, ord); err != nil {
+		return Order{}, err
+	}
+	return ord, nil
+}
+
+func (s *RiakStore) ClaimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.claimKey(k, ttl)
+}
+
+func (s *RiakStore) claimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	if time.Since(s.swept) >= riak_key_sweep {
+		s.sweepKeys()
+	}
+	var old IdempotencyKey
+	err := s.get("idempotency", k.Key, &old)
+	if err == nil && !old.expired() {
+		return old, ErrExists
+	}
+	if err != nil && err != ErrNotFound {
+		return IdempotencyKey{}, err
+	}
+	k = k.withTTL(ttl)
+	if err := s.put("idempotency", k.Key, k); err != nil {
+		return IdempotencyKey{}, err
+	}
+	return k, nil
+}
+
+func (s *RiakStore) ReclaimKey(old, k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var cur IdempotencyKey
+	err := s.get("idempotency", k.Key, &cur)
+	if err != nil && err != ErrNotFound {
+		return IdempotencyKey{}, err
+	}
+	if err == ErrNotFound || cur.OrderId != old.OrderId {
+		return s.claimKey(k, ttl)
+	}
+	k = k.withTTL(ttl)
+	if err := s.put("idempotency", k.Key, k); err != nil {
+		return IdempotencyKey{}, err
+	}
+	return k, nil
+}
+
+// Delete Expired Idempotency Keys
+// Best effort: a key that cannot be read or deleted is left for
+// the next sweep, and the claim goes ahead either way.
+func (s *RiakStore) sweepKeys() {
+	s.swept = time.Now()
+	keys, err := s.keys("idempotency")
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		var k IdempotencyKey
+		if s.get("idempotency", key, &k) == nil && k.expired() {
+			s.delete("idempotency", key)
+		}
+	}
+}
+
+func (s *RiakStore) ReleaseKey(key string) error {
+	err := s.delete("idempotency", key)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (s *RiakStore) Close() error {
+	return nil
+}
@@ -0,0 +1,89 @@
+package store_test
+
+import (
+	"encoding/json"
+	"gumball/store"
+	"gumball/store/storetest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRiakStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		ts := httptest.NewServer(newFakeRiak())
+		t.Cleanup(ts.Close)
+		s, err := store.OpenRiakStore(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+// Riak Stand-In
+// Serves /ping, /buckets/{bucket}/keys/{key} and key listing from memory.
+type fakeRiak struct {
+	mutex   sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func newFakeRiak() *fakeRiak {
+	return &fakeRiak{buckets: make(map[string]map[string][]byte)}
+}
+
+func (f *fakeRiak) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if req.URL.Path == "/ping" {
+		w.Write([]byte("OK"))
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/buckets/"), "/")
+	if len(parts) < 2 || parts[1] != "keys" {
+		http.NotFound(w, req)
+		return
+	}
+	bucket := f.buckets[parts[0]]
+	if bucket == nil {
+		bucket = make(map[string][]byte)
+		f.buckets[parts[0]] = bucket
+	}
+
+	if len(parts) == 2 {
+		var keys = []string{}
+		for key := range bucket {
+			keys = append(keys, key)
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"keys": keys})
+		return
+	}
+	key := parts[2]
+	switch req.Method {
+	case "GET":
+		value, ok := bucket[key]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(value)
+	case "PUT", "POST":
+		value, _ := ioutil.ReadAll(req.Body)
+		bucket[key] = value
+		w.WriteHeader(http.StatusNoContent)
+	case "DELETE":
+		if _, ok := bucket[key]; !ok {
+			http.NotFound(w, req)
+			return
+		}
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
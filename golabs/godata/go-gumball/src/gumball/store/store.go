@@ -0,0 +1,181 @@
+/*
+	Gumball API in Go
+	Storage Backends
+*/
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Machines are identified by serial number.  Version starts at 0
+// and counts changes to the inventory.  Fence is the highest lock
+// fencing token a change has been made with.
+type Machine struct {
+	CountGumballs int    `bson:"CountGumballs"`
+	ModelNumber   string `bson:"ModelNumber"`
+	SerialNumber  string `bson:"SerialNumber"`
+	Version       int64  `bson:"Version"`
+	Fence         int64  `bson:"Fence" json:",omitempty"`
+}
+
+// Orders belong to the machine they were placed on.
+type Order struct {
+	Id           string      `bson:"Id"`
+	SerialNumber string      `bson:"SerialNumber"`
+	OrderStatus  OrderStatus `bson:"OrderStatus"`
+	Reason       string      `bson:"Reason,omitempty" json:",omitempty"`
+}
+
+var (
+	ErrNotFound          = errors.New("store: not found")
+	ErrExists            = errors.New("store: already exists")
+	ErrOutOfStock        = errors.New("store: out of stock")
+	ErrInvalidTransition = errors.New("store: invalid order transition")
+	ErrInvalidInventory  = errors.New("store: inventory cannot be negative")
+	ErrVersionMismatch   = errors.New("store: machine version changed")
+	ErrStaleToken        = errors.New("store: stale fencing token")
+)
+
+// Gumball Machine Storage
+//
+// RegisterMachine adds a machine to the fleet and returns ErrExists
+// if the serial number is taken.  RetireMachine removes it; orders
+// already placed on a retired machine are kept but can no longer be
+// processed.
+//
+// Every inventory change, including a processed order, bumps the
+// machine's Version.  UpdateInventoryIf only sets the inventory while
+// the machine is still at version; otherwise it returns the current
+// machine with ErrVersionMismatch.
+type MachineStore interface {
+	ListMachines() ([]Machine, error)
+	GetMachine(serial string) (Machine, error)
+	RegisterMachine(m Machine) (Machine, error)
+	RetireMachine(serial string) error
+	UpdateInventory(serial string, count int) (Machine, error)
+	UpdateInventoryIf(serial string, version int64, count int) (Machine, error)
+}
+
+// Gumball Order Storage
+//
+// CreateOrder returns ErrNotFound unless the order's machine is
+// registered.  ProcessOrder takes a placed order through processing and takes
+// one gumball from its machine in the same step, leaving the order
+// dispensed.  When the machine is empty the order is failed with
+// ReasonOutOfStock and ErrOutOfStock is returned.  CancelOrder
+// cancels a placed order.  Both return ErrInvalidTransition for
+// orders in any other state.
+type OrderStore interface {
+	CreateOrder(ord Order) (Order, error)
+	GetOrder(id string) (Order, error)
+	ListOrders() ([]Order, error)
+	ProcessOrder(id string) (Order, error)
+	CancelOrder(id string) (Order, error)
+}
+
+// Fenced Inventory Storage
+//
+// Changes made while holding a lock lease on the machine carry the
+// lease's fencing token, which starts at 1 and grows with each
+// lease.  The machine records the highest token it has seen.  A
+// change with a lower token returns ErrStaleToken and changes
+// nothing, so a holder whose lease expired while it was paused
+// cannot undo the next holder's work.  Otherwise the methods behave
+// like UpdateInventoryIf and ProcessOrder.
+type FencedStore interface {
+	UpdateInventoryFenced(serial string, token, version int64, count int) (Machine, error)
+	ProcessOrderFenced(id string, token int64) (Order, error)
+}
+
+// Idempotency Key Storage
+//
+// ClaimKey stores k until ttl has passed.  While an unexpired key of
+// the same name is stored, that key is returned with ErrExists and
+// nothing is changed.  ReclaimKey takes over a claim whose request
+// never placed its order: if the key stored under k.Key still names
+// old.OrderId it is replaced by k, and otherwise ReclaimKey behaves
+// like ClaimKey.  ReleaseKey forgets a key, if it is stored, so a
+// request that failed can be retried under it.
+type IdempotencyStore interface {
+	ClaimKey(k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error)
+	ReclaimKey(old, k IdempotencyKey, ttl time.Duration) (IdempotencyKey, error)
+	ReleaseKey(key string) error
+}
+
+type Store interface {
+	MachineStore
+	OrderStore
+	FencedStore
+	IdempotencyStore
+	Close() error
+}
+
+// Check and Record a Fencing Token
+// Token 0 is an unfenced change and is always accepted.
+func (m *Machine) fence(token int64) error {
+	if token == 0 {
+		return nil
+	}
+	if token < m.Fence {
+		return ErrStaleToken
+	}
+	m.Fence = token
+	return nil
+}
+
+// Backend Config
+type Config struct {
+	Backend string
+	Serial  string
+	MySQL   string
+	Mongo   string
+	Redis   string
+	Riak    string
+}
+
+// Default Machine Registered by Open
+func DefaultMachine(serial string) Machine {
+	return Machine{
+		CountGumballs: 1000,
+		ModelNumber:   "M102988",
+		SerialNumber:  serial,
+	}
+}
+
+// Open Configured Backend
+// The machine for cfg.Serial is registered if it is missing.
+func Open(cfg Config) (Store, error) {
+	s, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Serial == "" {
+		return s, nil
+	}
+	_, err = s.RegisterMachine(DefaultMachine(cfg.Serial))
+	if err != nil && err != ErrExists {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "mysql":
+		return OpenMySQLStore(cfg.MySQL)
+	case "mongo":
+		return OpenMongoStore(cfg.Mongo)
+	case "redis":
+		return OpenRedisStore(cfg.Redis)
+	case "riak":
+		return OpenRiakStore(cfg.Riak)
+	}
+	return nil, fmt.Errorf("store: unknown backend %q", cfg.Backend)
+}
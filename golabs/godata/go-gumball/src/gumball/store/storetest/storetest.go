@@ -0,0 +1,643 @@
+/*
+	Gumball API in Go
+	Storage Backend Conformance Suite
+*/
+
+// Package storetest runs the same machine, order, inventory and
+// idempotency key scenarios against any store.Store.  Each backend's tests call Run
+// with a function that opens a fresh, empty store; Run registers
+// store.DefaultMachine(Serial) in it before each scenario.
+package storetest
+
+import (
+	"fmt"
+	"gumball/store"
+	"sync"
+	"testing"
+	"time"
+)
+
+type OpenFunc func(t *testing.T) store.Store
+
+// Serial Number of the Machine Registered by Run
+const Serial = "1234998871109"
+
+func Run(t *testing.T, open OpenFunc) {
+	scenarios := []struct {
+		name string
+		test func(t *testing.T, s store.Store)
+	}{
+		{"Machine", testMachine},
+		{"RegisterMachine", testRegisterMachine},
+		{"ListMachines", testListMachines},
+		{"RetireMachine", testRetireMachine},
+		{"UnknownMachine", testUnknownMachine},
+		{"OrdersPerMachine", testOrdersPerMachine},
+		{"UpdateInventory", testUpdateInventory},
+		{"NegativeInventory", testNegativeInventory},
+		{"UpdateInventoryIf", testUpdateInventoryIf},
+		{"UpdateInventoryFenced", testUpdateInventoryFenced},
+		{"CreateAndGetOrder", testCreateAndGetOrder},
+		{"ListOrders", testListOrders},
+		{"ProcessOrder", testProcessOrder},
+		{"ProcessOrderTwice", testProcessOrderTwice},
+		{"OutOfStock", testOutOfStock},
+		{"CancelOrder", testCancelOrder},
+		{"CancelOrderTwice", testCancelOrderTwice},
+		{"ProcessCancelledOrder", testProcessCancelledOrder},
+		{"UnknownOrder", testUnknownOrder},
+		{"ConcurrentProcessing", testConcurrentProcessing},
+		{"ProcessOrderFenced", testProcessOrderFenced},
+		{"ClaimKey", testClaimKey},
+		{"ReclaimKey", testReclaimKey},
+		{"ReleaseKey", testReleaseKey},
+		{"KeyExpires", testKeyExpires},
+		{"ConcurrentClaims", testConcurrentClaims},
+	}
+	for _, sc := range scenarios {
+		sc := sc
+		t.Run(sc.name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			if _, err := s.RegisterMachine(store.DefaultMachine(Serial)); err != nil {
+				t.Fatal(err)
+			}
+			sc.test(t, s)
+		})
+	}
+}
+
+func testMachine(t *testing.T, s store.Store) {
+	m, err := s.GetMachine(Serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := store.DefaultMachine(Serial); m != want {
+		t.Errorf("GetMachine = %+v, want %+v", m, want)
+	}
+}
+
+func testRegisterMachine(t *testing.T, s store.Store) {
+	want := store.Machine{CountGumballs: 7, ModelNumber: "M102988", SerialNumber: "serial-2"}
+	m, err := s.RegisterMachine(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != want {
+		t.Errorf("RegisterMachine = %+v, want %+v", m, want)
+	}
+	if m, err = s.GetMachine("serial-2"); err != nil || m != want {
+		t.Errorf("GetMachine = %+v, %v, want %+v", m, err, want)
+	}
+	dup := store.Machine{CountGumballs: 1, ModelNumber: "M1", SerialNumber: "serial-2"}
+	if _, err := s.RegisterMachine(dup); err != store.ErrExists {
+		t.Errorf("RegisterMachine(duplicate) = %v, want ErrExists", err)
+	}
+	neg := store.Machine{CountGumballs: -1, SerialNumber: "serial-3"}
+	if _, err := s.RegisterMachine(neg); err != store.ErrInvalidInventory {
+		t.Errorf("RegisterMachine(negative) = %v, want ErrInvalidInventory", err)
+	}
+	if m, _ = s.GetMachine("serial-2"); m != want {
+		t.Errorf("duplicate registration changed machine to %+v", m)
+	}
+}
+
+func testListMachines(t *testing.T, s store.Store) {
+	for i := 2; i <= 3; i++ {
+		newMachine(t, s, fmt.Sprintf("serial-%d", i), i)
+	}
+	machines, err := s.ListMachines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]int)
+	for _, m := range machines {
+		seen[m.SerialNumber] = m.CountGumballs
+	}
+	if len(machines) != 3 || seen[Serial] != 1000 || seen["serial-2"] != 2 || seen["serial-3"] != 3 {
+		t.Errorf("ListMachines = %+v", machines)
+	}
+}
+
+func testRetireMachine(t *testing.T, s store.Store) {
+	newMachine(t, s, "serial-2", 5)
+	newOrderOn(t, s, "serial-2", "order-1")
+	if err := s.RetireMachine("serial-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetMachine("serial-2"); err != store.ErrNotFound {
+		t.Errorf("GetMachine(retired) = %v, want ErrNotFound", err)
+	}
+	if err := s.RetireMachine("serial-2"); err != store.ErrNotFound {
+		t.Errorf("second RetireMachine = %v, want ErrNotFound", err)
+	}
+	machines, err := s.ListMachines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 1 || machines[0].SerialNumber != Serial {
+		t.Errorf("ListMachines after retire = %+v", machines)
+	}
+
+	// Orders on a retired machine are kept but cannot be filled
+	ord := store.Order{Id: "order-2", SerialNumber: "serial-2", OrderStatus: store.OrderPlaced}
+	if _, err := s.CreateOrder(ord); err != store.ErrNotFound {
+		t.Errorf("CreateOrder on retired machine = %v, want ErrNotFound", err)
+	}
+	if _, err := s.ProcessOrder("order-1"); err != store.ErrNotFound {
+		t.Errorf("ProcessOrder on retired machine = %v, want ErrNotFound", err)
+	}
+	expectStatus(t, s, "order-1", store.OrderPlaced)
+	if _, err := s.CancelOrder("order-1"); err != nil {
+		t.Errorf("CancelOrder on retired machine = %v", err)
+	}
+}
+
+func testUnknownMachine(t *testing.T, s store.Store) {
+	if _, err := s.GetMachine("missing"); err != store.ErrNotFound {
+		t.Errorf("GetMachine(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.UpdateInventory("missing", 5); err != store.ErrNotFound {
+		t.Errorf("UpdateInventory(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetMachine("missing"); err != store.ErrNotFound {
+		t.Errorf("UpdateInventory(missing) created a machine")
+	}
+	ord := store.Order{Id: "order-1", SerialNumber: "missing", OrderStatus: store.OrderPlaced}
+	if _, err := s.CreateOrder(ord); err != store.ErrNotFound {
+		t.Errorf("CreateOrder on missing machine = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetOrder("order-1"); err != store.ErrNotFound {
+		t.Errorf("order on missing machine was stored")
+	}
+}
+
+func testOrdersPerMachine(t *testing.T, s store.Store) {
+	newMachine(t, s, "serial-2", 1)
+	newOrderOn(t, s, "serial-2", "order-1")
+	newOrderOn(t, s, "serial-2", "order-2")
+	newOrder(t, s, "order-3")
+
+	ord, err := s.ProcessOrder("order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.SerialNumber != "serial-2" || ord.OrderStatus != store.OrderDispensed {
+		t.Errorf("ProcessOrder = %+v", ord)
+	}
+	if _, err := s.ProcessOrder("order-2"); err != store.ErrOutOfStock {
+		t.Errorf("ProcessOrder on empty machine = %v, want ErrOutOfStock", err)
+	}
+	if _, err := s.ProcessOrder("order-3"); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := s.GetMachine("serial-2"); m.CountGumballs != 0 {
+		t.Errorf("serial-2 CountGumballs = %d, want 0", m.CountGumballs)
+	}
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs-1)
+
+	got, err := s.GetOrder("order-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SerialNumber != "serial-2" {
+		t.Errorf("GetOrder serial = %q, want serial-2", got.SerialNumber)
+	}
+}
+
+func testUpdateInventory(t *testing.T, s store.Store) {
+	m, err := s.UpdateInventory(Serial, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CountGumballs != 42 {
+		t.Errorf("UpdateInventory returned %d gumballs, want 42", m.CountGumballs)
+	}
+	expectInventory(t, s, 42)
+}
+
+func testNegativeInventory(t *testing.T, s store.Store) {
+	if _, err := s.UpdateInventory(Serial, -1); err != store.ErrInvalidInventory {
+		t.Errorf("UpdateInventory(-1) = %v, want ErrInvalidInventory", err)
+	}
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs)
+}
+
+func testUpdateInventoryIf(t *testing.T, s store.Store) {
+	m, err := s.UpdateInventoryIf(Serial, 0, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CountGumballs != 42 || m.Version != 1 {
+		t.Errorf("UpdateInventoryIf = %+v, want 42 gumballs at version 1", m)
+	}
+	m, err = s.UpdateInventoryIf(Serial, 0, 7)
+	if err != store.ErrVersionMismatch {
+		t.Errorf("UpdateInventoryIf(stale) = %v, want ErrVersionMismatch", err)
+	}
+	if m.CountGumballs != 42 || m.Version != 1 {
+		t.Errorf("UpdateInventoryIf(stale) returned %+v, want the current machine", m)
+	}
+	expectInventory(t, s, 42)
+
+	// Orders bump the version too
+	newOrder(t, s, "order-1")
+	if _, err := s.ProcessOrder("order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateInventoryIf(Serial, 1, 7); err != store.ErrVersionMismatch {
+		t.Errorf("UpdateInventoryIf after order = %v, want ErrVersionMismatch", err)
+	}
+	if _, err := s.UpdateInventoryIf("missing", 0, 7); err != store.ErrNotFound {
+		t.Errorf("UpdateInventoryIf(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdateInventoryFenced(t *testing.T, s store.Store) {
+	m, err := s.UpdateInventoryFenced(Serial, 2, 0, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CountGumballs != 42 || m.Version != 1 || m.Fence != 2 {
+		t.Errorf("UpdateInventoryFenced = %+v, want 42 gumballs at version 1, fence 2", m)
+	}
+
+	// An older lease is fenced off even at the current version
+	m, err = s.UpdateInventoryFenced(Serial, 1, 1, 7)
+	if err != store.ErrStaleToken {
+		t.Errorf("UpdateInventoryFenced(old token) = %v, want ErrStaleToken", err)
+	}
+	if m.CountGumballs != 42 || m.Fence != 2 {
+		t.Errorf("UpdateInventoryFenced(old token) returned %+v, want the current machine", m)
+	}
+	if _, err := s.UpdateInventoryFenced(Serial, 3, 0, 7); err != store.ErrVersionMismatch {
+		t.Errorf("UpdateInventoryFenced(stale version) = %v, want ErrVersionMismatch", err)
+	}
+	if _, err := s.UpdateInventoryFenced(Serial, 2, 1, 7); err != nil {
+		t.Errorf("UpdateInventoryFenced(same token) = %v", err)
+	}
+	if _, err := s.UpdateInventoryFenced(Serial, 0, 2, 7); err != store.ErrStaleToken {
+		t.Errorf("UpdateInventoryFenced(no token) = %v, want ErrStaleToken", err)
+	}
+	if _, err := s.UpdateInventoryFenced("missing", 5, 0, 7); err != store.ErrNotFound {
+		t.Errorf("UpdateInventoryFenced(missing) = %v, want ErrNotFound", err)
+	}
+	expectInventory(t, s, 7)
+}
+
+func testCreateAndGetOrder(t *testing.T, s store.Store) {
+	ord := newOrder(t, s, "order-1")
+	if ord.OrderStatus != store.OrderPlaced {
+		t.Errorf("CreateOrder status = %q, want %q", ord.OrderStatus, store.OrderPlaced)
+	}
+	got, err := s.GetOrder("order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ord {
+		t.Errorf("GetOrder = %+v, want %+v", got, ord)
+	}
+}
+
+func testListOrders(t *testing.T, s store.Store) {
+	orders, err := s.ListOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("empty store lists %d orders", len(orders))
+	}
+	for i := 0; i < 3; i++ {
+		newOrder(t, s, fmt.Sprintf("order-%d", i))
+	}
+	orders, err = s.ListOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, ord := range orders {
+		seen[ord.Id] = true
+	}
+	if len(orders) != 3 || len(seen) != 3 {
+		t.Errorf("ListOrders = %+v, want 3 distinct orders", orders)
+	}
+}
+
+func testProcessOrder(t *testing.T, s store.Store) {
+	newOrder(t, s, "order-1")
+	ord, err := s.ProcessOrder("order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.OrderStatus != store.OrderDispensed {
+		t.Errorf("ProcessOrder status = %q, want %q", ord.OrderStatus, store.OrderDispensed)
+	}
+	expectStatus(t, s, "order-1", store.OrderDispensed)
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs-1)
+}
+
+func testProcessOrderTwice(t *testing.T, s store.Store) {
+	newOrder(t, s, "order-1")
+	if _, err := s.ProcessOrder("order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ProcessOrder("order-1"); err != store.ErrInvalidTransition {
+		t.Errorf("second ProcessOrder = %v, want ErrInvalidTransition", err)
+	}
+	expectStatus(t, s, "order-1", store.OrderDispensed)
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs-1)
+}
+
+func testOutOfStock(t *testing.T, s store.Store) {
+	if _, err := s.UpdateInventory(Serial, 0); err != nil {
+		t.Fatal(err)
+	}
+	newOrder(t, s, "order-1")
+	ord, err := s.ProcessOrder("order-1")
+	if err != store.ErrOutOfStock {
+		t.Fatalf("ProcessOrder with no gumballs = %v, want ErrOutOfStock", err)
+	}
+	if ord.OrderStatus != store.OrderFailed || ord.Reason != store.ReasonOutOfStock {
+		t.Errorf("ProcessOrder with no gumballs = %+v, want failed out of stock", ord)
+	}
+	got, err := s.GetOrder("order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ord {
+		t.Errorf("GetOrder = %+v, want %+v", got, ord)
+	}
+	expectInventory(t, s, 0)
+}
+
+func testCancelOrder(t *testing.T, s store.Store) {
+	newOrder(t, s, "order-1")
+	ord, err := s.CancelOrder("order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.OrderStatus != store.OrderCancelled {
+		t.Errorf("CancelOrder status = %q, want %q", ord.OrderStatus, store.OrderCancelled)
+	}
+	expectStatus(t, s, "order-1", store.OrderCancelled)
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs)
+}
+
+func testCancelOrderTwice(t *testing.T, s store.Store) {
+	newOrder(t, s, "order-1")
+	if _, err := s.CancelOrder("order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelOrder("order-1"); err != store.ErrInvalidTransition {
+		t.Errorf("second CancelOrder = %v, want ErrInvalidTransition", err)
+	}
+	newOrder(t, s, "order-2")
+	if _, err := s.ProcessOrder("order-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CancelOrder("order-2"); err != store.ErrInvalidTransition {
+		t.Errorf("CancelOrder after dispensing = %v, want ErrInvalidTransition", err)
+	}
+	expectStatus(t, s, "order-2", store.OrderDispensed)
+}
+
+func testProcessCancelledOrder(t *testing.T, s store.Store) {
+	newOrder(t, s, "order-1")
+	if _, err := s.CancelOrder("order-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ProcessOrder("order-1"); err != store.ErrInvalidTransition {
+		t.Errorf("ProcessOrder after cancel = %v, want ErrInvalidTransition", err)
+	}
+	expectStatus(t, s, "order-1", store.OrderCancelled)
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs)
+}
+
+func testUnknownOrder(t *testing.T, s store.Store) {
+	if _, err := s.GetOrder("missing"); err != store.ErrNotFound {
+		t.Errorf("GetOrder(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.ProcessOrder("missing"); err != store.ErrNotFound {
+		t.Errorf("ProcessOrder(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.CancelOrder("missing"); err != store.ErrNotFound {
+		t.Errorf("CancelOrder(missing) = %v, want ErrNotFound", err)
+	}
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs)
+}
+
+func testConcurrentProcessing(t *testing.T, s store.Store) {
+	const stock, count = 5, 20
+	if _, err := s.UpdateInventory(Serial, stock); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		newOrder(t, s, fmt.Sprintf("order-%d", i))
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	processed, refused := 0, 0
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := s.ProcessOrder(id)
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch err {
+			case nil:
+				processed++
+			case store.ErrOutOfStock:
+				refused++
+			default:
+				t.Errorf("ProcessOrder(%s): %v", id, err)
+			}
+		}(fmt.Sprintf("order-%d", i))
+	}
+	wg.Wait()
+
+	if processed != stock || refused != count-stock {
+		t.Errorf("processed %d and refused %d orders, want %d and %d", processed, refused, stock, count-stock)
+	}
+	expectInventory(t, s, 0)
+}
+
+func testProcessOrderFenced(t *testing.T, s store.Store) {
+	newOrder(t, s, "order-1")
+	newOrder(t, s, "order-2")
+	ord, err := s.ProcessOrderFenced("order-1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.OrderStatus != store.OrderDispensed {
+		t.Errorf("ProcessOrderFenced status = %q, want %q", ord.OrderStatus, store.OrderDispensed)
+	}
+	m, err := s.GetMachine(Serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Fence != 5 {
+		t.Errorf("Fence = %d, want 5", m.Fence)
+	}
+
+	if _, err := s.ProcessOrderFenced("order-2", 4); err != store.ErrStaleToken {
+		t.Errorf("ProcessOrderFenced(old token) = %v, want ErrStaleToken", err)
+	}
+	expectStatus(t, s, "order-2", store.OrderPlaced)
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs-1)
+
+	// Unfenced changes are still accepted
+	if _, err := s.ProcessOrder("order-2"); err != nil {
+		t.Fatal(err)
+	}
+	expectInventory(t, s, store.DefaultMachine("").CountGumballs-2)
+}
+
+func testClaimKey(t *testing.T, s store.Store) {
+	first := store.IdempotencyKey{Key: "key-1", Fingerprint: "body-1", OrderId: "order-1"}
+	claimed, err := s.ClaimKey(first, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed.Key != first.Key || claimed.Fingerprint != first.Fingerprint || claimed.OrderId != first.OrderId {
+		t.Errorf("ClaimKey = %+v, want %+v", claimed, first)
+	}
+
+	// A second claim gets the first one back, whatever it asked for
+	old, err := s.ClaimKey(store.IdempotencyKey{Key: "key-1", Fingerprint: "body-2", OrderId: "order-2"}, time.Hour)
+	if err != store.ErrExists {
+		t.Fatalf("second ClaimKey = %v, want ErrExists", err)
+	}
+	if old.Fingerprint != "body-1" || old.OrderId != "order-1" {
+		t.Errorf("second ClaimKey = %+v, want the first claim", old)
+	}
+	if _, err := s.ClaimKey(store.IdempotencyKey{Key: "key-2", Fingerprint: "body-1", OrderId: "order-3"}, time.Hour); err != nil {
+		t.Errorf("ClaimKey(key-2) = %v", err)
+	}
+}
+
+func testReclaimKey(t *testing.T, s store.Store) {
+	first := claimKey(t, s, "key-1", "order-1", time.Hour)
+	if first.ClaimedBefore(time.Now().Add(-time.Minute)) || !first.ClaimedBefore(time.Now().Add(time.Second)) {
+		t.Errorf("ClaimedAt = %d, want now", first.ClaimedAt)
+	}
+
+	// The claim for order-1 is taken over once
+	second, err := s.ReclaimKey(first, store.IdempotencyKey{Key: "key-1", Fingerprint: "body", OrderId: "order-2"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.OrderId != "order-2" {
+		t.Errorf("ReclaimKey = %+v, want order-2", second)
+	}
+	cur, err := s.ReclaimKey(first, store.IdempotencyKey{Key: "key-1", Fingerprint: "body", OrderId: "order-3"}, time.Hour)
+	if err != store.ErrExists {
+		t.Fatalf("second ReclaimKey = %v, want ErrExists", err)
+	}
+	if cur.OrderId != "order-2" {
+		t.Errorf("second ReclaimKey = %+v, want the order-2 claim", cur)
+	}
+
+	// A released key is claimed afresh
+	if err := s.ReleaseKey("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if k, err := s.ReclaimKey(second, store.IdempotencyKey{Key: "key-1", Fingerprint: "body", OrderId: "order-4"}, time.Hour); err != nil || k.OrderId != "order-4" {
+		t.Errorf("ReclaimKey after release = %+v, %v", k, err)
+	}
+}
+
+func testReleaseKey(t *testing.T, s store.Store) {
+	claimKey(t, s, "key-1", "order-1", time.Hour)
+	if err := s.ReleaseKey("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	claimKey(t, s, "key-1", "order-2", time.Hour)
+	if err := s.ReleaseKey("missing"); err != nil {
+		t.Errorf("ReleaseKey(missing) = %v", err)
+	}
+}
+
+func testKeyExpires(t *testing.T, s store.Store) {
+	claimKey(t, s, "key-1", "order-1", 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	claimed := claimKey(t, s, "key-1", "order-2", time.Hour)
+	if claimed.OrderId != "order-2" {
+		t.Errorf("ClaimKey after expiry = %+v", claimed)
+	}
+}
+
+func testConcurrentClaims(t *testing.T, s store.Store) {
+	const count = 10
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	winners := 0
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := s.ClaimKey(store.IdempotencyKey{Key: "key-1", Fingerprint: "body", OrderId: id}, time.Hour)
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch err {
+			case nil:
+				winners++
+			case store.ErrExists:
+			default:
+				t.Errorf("ClaimKey(%s): %v", id, err)
+			}
+		}(fmt.Sprintf("order-%d", i))
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("%d claims of one key succeeded, want 1", winners)
+	}
+}
+
+func newMachine(t *testing.T, s store.Store, serial string, count int) {
+	m := store.Machine{CountGumballs: count, ModelNumber: "M102988", SerialNumber: serial}
+	if _, err := s.RegisterMachine(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newOrder(t *testing.T, s store.Store, id string) store.Order {
+	return newOrderOn(t, s, Serial, id)
+}
+
+func newOrderOn(t *testing.T, s store.Store, serial, id string) store.Order {
+	ord, err := s.CreateOrder(store.Order{Id: id, SerialNumber: serial, OrderStatus: store.OrderPlaced})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ord
+}
+
+func claimKey(t *testing.T, s store.Store, key, order string, ttl time.Duration) store.IdempotencyKey {
+	k, err := s.ClaimKey(store.IdempotencyKey{Key: key, Fingerprint: "body", OrderId: order}, ttl)
+	if err != nil {
+		t.Fatalf("ClaimKey(%s): %v", key, err)
+	}
+	return k
+}
+
+func expectStatus(t *testing.T, s store.Store, id string, status store.OrderStatus) {
+	ord, err := s.GetOrder(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.OrderStatus != status {
+		t.Errorf("order %s status = %q, want %q", id, ord.OrderStatus, status)
+	}
+}
+
+func expectInventory(t *testing.T, s store.Store, count int) {
+	m, err := s.GetMachine(Serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.CountGumballs != count {
+		t.Errorf("CountGumballs = %d, want %d", m.CountGumballs, count)
+	}
+}